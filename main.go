@@ -1,23 +1,74 @@
 package main
 
 import (
+    "bufio"
+    "flag"
+    "io"
     "log"
     "os"
+    "strings"
+    "time"
 
     "github.com/Myagchiev/final-project/pkg/db"
     "github.com/Myagchiev/final-project/pkg/server"
 )
 
 func main() {
+    migrateOnly := flag.Bool("migrate", false, "применить миграции базы данных и завершить работу")
+    addUser := flag.String("adduser", "", "создать пользователя с этим логином (пароль читается из stdin) и завершить работу")
+    flag.Parse()
+
     dbFile := os.Getenv("TODO_DBFILE")
     if dbFile == "" {
         dbFile = "scheduler.db"
     }
 
-    err := db.Init(dbFile)
+    // TODO_DB_DSN переключает хранилище с файла SQLite на PostgreSQL.
+    open, newStore, source := db.OpenSQLite, db.NewSQLiteStore, dbFile
+    if dsn := os.Getenv("TODO_DB_DSN"); dsn != "" {
+        open, newStore, source = db.OpenPostgres, db.NewPostgresStore, dsn
+    }
+
+    if *migrateOnly {
+        store, err := open(source)
+        if err != nil {
+            log.Fatalf("Ошибка открытия БД: %v", err)
+        }
+        defer store.Close()
+        from, to, err := store.Migrate()
+        if err != nil {
+            log.Fatalf("Ошибка миграции БД: %v", err)
+        }
+        log.Printf("Версия схемы БД: %d -> %d", from, to)
+        return
+    }
+
+    store, err := newStore(source)
     if err != nil {
         log.Fatalf("Ошибка инициализации БД: %v", err)
     }
+    defer store.Close()
+
+    if *addUser != "" {
+        password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+        if err != nil && err != io.EOF {
+            log.Fatalf("Ошибка чтения пароля: %v", err)
+        }
+        id, err := store.AddUser(*addUser, strings.TrimRight(password, "\r\n"))
+        if err != nil {
+            log.Fatalf("Ошибка создания пользователя: %v", err)
+        }
+        log.Printf("Создан пользователь %s с ID %d", *addUser, id)
+        return
+    }
+
+    if window := os.Getenv("TODO_UNDO_WINDOW"); window != "" {
+        d, err := time.ParseDuration(window)
+        if err != nil || d <= 0 {
+            log.Fatalf("Неверное значение TODO_UNDO_WINDOW: %q", window)
+        }
+        store.UndoWindow = d
+    }
 
-    server.Run()
+    server.Run(store)
 }
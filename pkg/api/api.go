@@ -2,38 +2,187 @@
 package api
 
 import (
+    "errors"
     "fmt"
     "net/http"
+    "strconv"
     "time"
 
+    "github.com/Myagchiev/final-project/pkg/db"
     "github.com/Myagchiev/final-project/pkg/utils"
 )
 
-func Init() {
-    http.HandleFunc("/api/nextdate", NextDateHandler)
-    http.HandleFunc("/api/signin", SignInHandler)
-    http.HandleFunc("/api/task", Auth(taskCRUDHandler))
-    http.HandleFunc("/api/tasks", Auth(tasksListHandler))
-    http.HandleFunc("/api/task/done", Auth(taskCRUDHandler))
+// API — обработчики HTTP API поверх хранилища задач.
+type API struct {
+    store   db.TaskStore
+    limiter *loginLimiter
+}
+
+func New(store db.TaskStore) *API {
+    return &API{store: store, limiter: newLoginLimiter()}
+}
+
+// Register подключает обработчики API к mux.
+func (a *API) Register(mux *http.ServeMux) {
+    mux.HandleFunc("/api/nextdate", NextDateHandler)
+    mux.HandleFunc("/api/nextdates", NextDatesHandler)
+    mux.HandleFunc("/api/repeat", RepeatHandler)
+    mux.HandleFunc("/api/signin", a.signInHandler)
+    mux.HandleFunc("/api/refresh", a.refreshHandler)
+    mux.HandleFunc("/api/signout", a.Auth(a.signOutHandler))
+    mux.HandleFunc("/api/sessions", a.Auth(a.sessionsHandler))
+    mux.HandleFunc("/api/keys", a.Auth(a.apiKeysHandler))
+    mux.HandleFunc("/api/task", a.Auth(a.taskCRUDHandler))
+    mux.HandleFunc("/api/tasks", a.Auth(a.tasksListHandler))
+    mux.HandleFunc("/api/focus", a.Auth(a.focusHandler))
+    mux.HandleFunc("/api/task/done", a.Auth(a.taskCRUDHandler))
+    mux.HandleFunc("/api/task/checklist", a.Auth(a.checklistHandler))
+    mux.HandleFunc("/api/task/deps", a.Auth(a.depsHandler))
+    mux.HandleFunc("/api/task/history", a.Auth(a.taskHistoryHandler))
+    mux.HandleFunc("/api/completed", a.Auth(a.completedHandler))
+    mux.HandleFunc("/api/task/restore", a.Auth(a.restoreTaskHandler))
+    mux.HandleFunc("/api/trash", a.Auth(a.trashHandler))
+    mux.HandleFunc("/api/projects", a.Auth(a.projectsHandler))
+    mux.HandleFunc("/api/project", a.Auth(a.projectHandler))
+    mux.HandleFunc("/api/share", a.Auth(a.shareHandler))
+    mux.HandleFunc("/api/tags", a.Auth(a.tagsHandler))
+    mux.HandleFunc("/api/tag", a.Auth(a.tagHandler))
+}
+
+const (
+    defaultPreviewLimit = 10
+    maxPreviewLimit     = 100
+)
+
+type NextDatesResp struct {
+    Dates []string `json:"dates"`
+}
+
+// RepeatResp — правило повторения в обоих форматах.
+type RepeatResp struct {
+    // Repeat — короткий формат; пусто, если правило в нём не выражается.
+    Repeat string `json:"repeat,omitempty"`
+    RRule  string `json:"rrule"`
+}
+
+// RepeatHandler переводит правило repeat из короткого формата в RRULE и
+// обратно и возвращает оба вида.
+func RepeatHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    rrule, err := utils.ToRRule(r.FormValue("repeat"))
+    if err != nil {
+        writeJSONError(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    // не выразимое в коротком формате правило остаётся только в виде RRULE
+    short, _ := utils.FromRRule(rrule)
+    writeJSON(w, RepeatResp{Repeat: short, RRule: rrule})
 }
 
 func NextDateHandler(w http.ResponseWriter, r *http.Request) {
-    nowStr := r.FormValue("now")
     dateStr := r.FormValue("date")
     repeat := r.FormValue("repeat")
 
-    now := time.Now()
-    if nowStr != "" {
-        if parsed, err := time.Parse(utils.DateLayout, nowStr); err == nil {
-            now = parsed
-        }
+    now, err := requestNow(r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
     }
 
-    next, err := utils.NextDate(now, dateStr, repeat)
+    end, err := requestRepeatEnd(r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    next, _, err := utils.NextDateEnd(now, dateStr, r.FormValue("time"), repeat, end)
     if err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
 
     fmt.Fprint(w, next)
-}
\ No newline at end of file
+}
+
+// NextDatesHandler возвращает ближайшие даты повторения правила:
+// не больше limit дат после now, при необходимости в диапазоне from..to.
+func NextDatesHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    now, err := requestNow(r)
+    if err != nil {
+        writeJSONError(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    end, err := requestRepeatEnd(r)
+    if err != nil {
+        writeJSONError(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    limit := defaultPreviewLimit
+    if limitStr := r.FormValue("limit"); limitStr != "" {
+        limit, err = strconv.Atoi(limitStr)
+        if err != nil || limit < 1 || limit > maxPreviewLimit {
+            writeJSONError(w, fmt.Sprintf("limit должен быть от 1 до %d", maxPreviewLimit), http.StatusBadRequest)
+            return
+        }
+    }
+
+    var from, to time.Time
+    if fromStr := r.FormValue("from"); fromStr != "" {
+        if from, err = time.Parse(utils.DateLayout, fromStr); err != nil {
+            writeJSONError(w, "неверная дата from", http.StatusBadRequest)
+            return
+        }
+    }
+    if toStr := r.FormValue("to"); toStr != "" {
+        if to, err = time.Parse(utils.DateLayout, toStr); err != nil {
+            writeJSONError(w, "неверная дата to", http.StatusBadRequest)
+            return
+        }
+    }
+
+    it, err := utils.NewOccurrences(r.FormValue("date"), r.FormValue("repeat"), end)
+    if err != nil {
+        writeJSONError(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    today := now.Format(utils.DateLayout)
+    dates := make([]string, 0, limit)
+    for len(dates) < limit {
+        date, ok := it.Next()
+        if !ok || (!to.IsZero() && date.After(to)) {
+            break
+        }
+        if next := date.Format(utils.DateLayout); next > today && !date.Before(from) {
+            dates = append(dates, next)
+        }
+    }
+
+    if err := it.Err(); err != nil && !errors.Is(err, utils.ErrSeriesEnded) && len(dates) == 0 {
+        writeJSONError(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    writeJSON(w, NextDatesResp{Dates: dates})
+}
+
+func requestRepeatEnd(r *http.Request) (utils.RepeatEnd, error) {
+    end := utils.RepeatEnd{Until: r.FormValue("until")}
+    if countStr := r.FormValue("count"); countStr != "" {
+        count, err := strconv.Atoi(countStr)
+        if err != nil {
+            return end, errors.New("неверное число повторений")
+        }
+        end.Count = count
+    }
+    return end, nil
+}
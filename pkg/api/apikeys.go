@@ -0,0 +1,100 @@
+// pkg/api/apikeys.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+// apiKeyPrefix отличает ключи API от других токенов; вместе с первыми
+// символами случайной части он показывается в списке ключей.
+const (
+	apiKeyPrefix     = "pk_"
+	apiKeyVisibleLen = len(apiKeyPrefix) + 8
+)
+
+type APIKeysResp struct {
+	Keys []db.APIKey `json:"keys"`
+}
+
+type apiKeyRequest struct {
+	Name     string `json:"name"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+type apiKeyResponse struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Prefix string `json:"prefix"`
+}
+
+// apiKeysHandler управляет ключами API пользователя: GET — список,
+// POST с name и read_only — новый ключ (значение возвращается только
+// в этом ответе), DELETE ?id= — отозвать. Управлять ключами можно только
+// после входа, но не с ключом API.
+func (a *API) apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if requestAPIKey(r) != nil {
+		writeJSONError(w, "ключами API нельзя управлять с ключом API", http.StatusForbidden)
+		return
+	}
+	store := a.storeFor(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := store.APIKeys()
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, APIKeysResp{Keys: keys})
+
+	case http.MethodPost:
+		var req apiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			writeJSONError(w, "не указано название ключа", http.StatusBadRequest)
+			return
+		}
+		if len([]rune(req.Name)) > 255 {
+			writeJSONError(w, "название ключа длиннее 255 символов", http.StatusBadRequest)
+			return
+		}
+
+		secret := apiKeyPrefix + randomToken()
+		key := db.APIKey{Name: req.Name, Prefix: secret[:apiKeyVisibleLen], ReadOnly: req.ReadOnly}
+		id, err := store.AddAPIKey(key, secret)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, apiKeyResponse{ID: strconv.Itoa(id), Key: secret, Prefix: key.Prefix})
+
+	case http.MethodDelete:
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			writeJSONError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if err := store.RevokeAPIKey(id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, db.ErrAPIKeyNotFound) {
+				status = http.StatusNotFound
+			}
+			writeJSONError(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, map[string]interface{}{})
+
+	default:
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -3,30 +3,59 @@ package api
 
 import (
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/Myagchiev/final-project/pkg/db"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// signInRequest — вход по учётной записи или, без логина, по общему
+// паролю TODO_PASSWORD.
 type signInRequest struct {
+	Login    string `json:"login"`
 	Password string `json:"password"`
 }
 
 type signInResponse struct {
 	Token string `json:"token"`
+	// RefreshToken обменивается на новую пару токенов в /api/refresh.
+	RefreshToken string `json:"refresh_token"`
 }
 
 type Claims struct {
+	// UserID — пользователь токена; 0 — общий аккаунт.
+	UserID int `json:"uid,omitempty"`
+	// SessionID — сессия, в которой выдан токен; ID токена — поле jti.
+	SessionID string `json:"sid,omitempty"`
+	// PasswordHash — отпечаток пароля, с которым выдан токен.
 	PasswordHash string `json:"ph"`
 	jwt.RegisteredClaims
 }
 
+const (
+	defaultAccessTTL = 15 * time.Minute
+	// refreshTTL — сколько живёт сессия без обновления токенов.
+	refreshTTL = 30 * 24 * time.Hour
+
+	refreshCookie = "refresh"
+	refreshPath   = "/api/refresh"
+)
+
 var (
 	jwtKey = []byte(os.Getenv("TODOTODO_JWT_SECRET"))
+	// accessTTL — срок действия access-токена, TODO_ACCESS_TTL.
+	accessTTL = defaultAccessTTL
 )
 
 func init() {
@@ -34,9 +63,17 @@ func init() {
 		jwtKey = []byte("my_secret_key")
 		fmt.Println("WARNING: JWT secret not set – using default")
 	}
+	if ttl := os.Getenv("TODO_ACCESS_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			fmt.Printf("WARNING: invalid TODO_ACCESS_TTL %q – using %s\n", ttl, defaultAccessTTL)
+			return
+		}
+		accessTTL = d
+	}
 }
 
-func SignInHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) signInHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -48,41 +85,308 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expected := os.Getenv("TODO_PASSWORD")
-	if expected == "" {
-		writeJSONError(w, "password not set", http.StatusInternalServerError)
+	ip := clientIP(r)
+	wait, backoff := a.limiter.allow(ip)
+	if wait > 0 {
+		logFailedSignIn(ip, req.Login, "rate limited")
+		writeTooManyAttempts(w, wait)
 		return
 	}
+	// failed сообщает о неудаче, уже учтённой в allow, и о том, когда можно
+	// повторить попытку.
+	failed := func(msg string) {
+		reason := "invalid credentials"
+		if backoff >= maxBackoff {
+			reason += ", locked out"
+		}
+		logFailedSignIn(ip, req.Login, reason)
+		if backoff > 0 {
+			setRetryAfter(w, backoff)
+		}
+		writeJSONError(w, msg, http.StatusUnauthorized)
+	}
+
+	var userID int
+	if req.Login != "" {
+		user, err := a.store.Authenticate(req.Login, req.Password)
+		if errors.Is(err, db.ErrInvalidCredentials) {
+			failed(err.Error())
+			return
+		}
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		userID = user.ID
+	} else {
+		expected := os.Getenv("TODO_PASSWORD")
+		if expected == "" {
+			writeJSONError(w, "password not set", http.StatusInternalServerError)
+			return
+		}
+
+		if !passwordsEqual(req.Password, expected) {
+			failed("Неверный пароль")
+			return
+		}
+	}
+	a.limiter.succeed(ip)
 
-	if req.Password != expected {
-		writeJSONError(w, "Неверный пароль", http.StatusUnauthorized)
+	fingerprint, err := a.passwordHash(userID)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	expirationTime := time.Now().Add(8 * time.Hour)
-	claims := &Claims{
-		PasswordHash: hashPassword(expected),
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+	sess := db.Session{
+		ID:           randomToken(),
+		UserID:       userID,
+		PasswordHash: fingerprint,
+		UserAgent:    r.UserAgent(),
+		IP:           clientIP(r),
+	}
+	access, refresh, err := newTokens(&sess, fingerprint)
+	if err != nil {
+		writeJSONError(w, "token error", http.StatusInternalServerError)
+		return
+	}
+	if err := a.store.AddSession(sess, refresh); err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeTokens(w, sess, access, refresh)
+}
+
+// refreshHandler обменивает refresh-токен из куки или тела запроса на новую
+// пару токенов. Прежний refresh-токен после этого недействителен.
+func (a *API) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if cookie, err := r.Cookie(refreshCookie); err == nil {
+		req.RefreshToken = cookie.Value
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if req.RefreshToken == "" {
+		writeJSONError(w, "refresh_token is empty", http.StatusBadRequest)
+		return
+	}
+
+	var next db.Session
+	access, refresh, err := newTokens(&next, "")
+	if err != nil {
+		writeJSONError(w, "token error", http.StatusInternalServerError)
+		return
+	}
+	sess, err := a.store.RotateSession(req.RefreshToken, refresh, next)
+	if errors.Is(err, db.ErrSessionNotFound) || errors.Is(err, db.ErrRefreshReused) {
+		writeJSONError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Отпечаток пароля известен только после того, как нашлась сессия.
+	// Сессию, открытую до смены пароля, не продлеваем, а закрываем.
+	fingerprint, err := a.passwordHash(sess.UserID)
+	if err != nil || fingerprint != sess.PasswordHash {
+		a.store.ForUser(sess.UserID).RevokeSession(sess.ID)
+		writeJSONError(w, "Password changed", http.StatusUnauthorized)
+		return
+	}
+	access, err = signAccessToken(sess, fingerprint)
 	if err != nil {
 		writeJSONError(w, "token error", http.StatusInternalServerError)
 		return
 	}
+	writeTokens(w, sess, access, refresh)
+}
+
+// newTokens заводит в sess новый access-токен и возвращает его вместе с
+// новым refresh-токеном. Пустой fingerprint — access-токен подписывается
+// позже, через signAccessToken.
+func newTokens(sess *db.Session, fingerprint string) (access, refresh string, err error) {
+	now := time.Now()
+	sess.AccessJTI = randomToken()
+	sess.AccessExpiresAt = now.Add(accessTTL).UTC().Format(time.RFC3339)
+	sess.ExpiresAt = now.Add(refreshTTL).UTC().Format(time.RFC3339)
+	if fingerprint != "" {
+		if access, err = signAccessToken(*sess, fingerprint); err != nil {
+			return "", "", err
+		}
+	}
+	return access, randomToken(), nil
+}
+
+func signAccessToken(sess db.Session, fingerprint string) (string, error) {
+	expires, err := time.Parse(time.RFC3339, sess.AccessExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		UserID:       sess.UserID,
+		SessionID:    sess.ID,
+		PasswordHash: fingerprint,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sess.AccessJTI,
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+}
 
+func writeTokens(w http.ResponseWriter, sess db.Session, access, refresh string) {
+	accessExpires, _ := time.Parse(time.RFC3339, sess.AccessExpiresAt)
+	refreshExpires, _ := time.Parse(time.RFC3339, sess.ExpiresAt)
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
-		Value:    tokenString,
-		Expires:  expirationTime,
+		Value:    access,
+		Expires:  accessExpires,
 		Path:     "/",
 		HttpOnly: true,
 	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refresh,
+		Expires:  refreshExpires,
+		Path:     refreshPath,
+		HttpOnly: true,
+	})
+
+	writeJSON(w, signInResponse{Token: access, RefreshToken: refresh})
+}
+
+// signOutHandler закрывает текущую сессию: её токены больше не действуют.
+func (a *API) signOutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if requestAPIKey(r) != nil {
+		writeJSONError(w, "с ключом API нельзя завершать сессии", http.StatusForbidden)
+		return
+	}
+
+	if claims := requestClaims(r); claims != nil {
+		store := a.storeFor(r)
+		if err := store.RevokeSession(claims.SessionID); err != nil && !errors.Is(err, db.ErrSessionNotFound) {
+			writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := store.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+			writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for _, c := range []*http.Cookie{{Name: "token", Path: "/"}, {Name: refreshCookie, Path: refreshPath}} {
+		c.MaxAge = -1
+		c.HttpOnly = true
+		http.SetCookie(w, c)
+	}
+	writeJSON(w, map[string]interface{}{})
+}
+
+type SessionsResp struct {
+	Sessions []db.Session `json:"sessions"`
+}
+
+// sessionsHandler показывает сессии пользователя (GET) и закрывает сессию
+// по id (DELETE). С ключом API сессии недоступны.
+func (a *API) sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if requestAPIKey(r) != nil {
+		writeJSONError(w, "сессиями нельзя управлять с ключом API", http.StatusForbidden)
+		return
+	}
+	store := a.storeFor(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		sessions, err := store.Sessions()
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if claims := requestClaims(r); claims != nil {
+			for i := range sessions {
+				sessions[i].Current = sessions[i].ID == claims.SessionID
+			}
+		}
+		writeJSON(w, SessionsResp{Sessions: sessions})
+
+	case http.MethodDelete:
+		id := r.FormValue("id")
+		if id == "" {
+			writeJSONError(w, "id is empty", http.StatusBadRequest)
+			return
+		}
+		if err := store.RevokeSession(id); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{})
+
+	default:
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// randomToken возвращает случайную строку для ID сессий и токенов.
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// trustProxy — сервер стоит за обратным прокси (TODO_TRUST_PROXY=1), и адрес
+// клиента берётся из последнего элемента X-Forwarded-For, который добавил
+// прокси.
+var trustProxy = os.Getenv("TODO_TRUST_PROXY") == "1"
+
+// clientIP — адрес клиента без порта.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); trustProxy && forwarded != "" {
+		parts := strings.Split(forwarded, ",")
+		return strings.TrimSpace(parts[len(parts)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// passwordHash возвращает отпечаток текущего пароля пользователя: общего
+// пароля для аккаунта 0 или bcrypt-хеша учётной записи.
+func (a *API) passwordHash(userID int) (string, error) {
+	if userID == 0 {
+		pass := os.Getenv("TODO_PASSWORD")
+		if pass == "" {
+			return "", errors.New("password not set")
+		}
+		return hashPassword(pass), nil
+	}
+	user, err := a.store.GetUser(userID)
+	if err != nil {
+		return "", err
+	}
+	return hashPassword(user.PasswordHash), nil
+}
 
-	writeJSON(w, signInResponse{Token: tokenString})
+// passwordsEqual сравнивает пароли за время, не зависящее от совпадения.
+// Сравниваются хеши, чтобы не выдать и длину пароля.
+func passwordsEqual(got, expected string) bool {
+	g, e := sha256.Sum256([]byte(got)), sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(g[:], e[:]) == 1
 }
 
 func hashPassword(p string) string {
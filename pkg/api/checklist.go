@@ -0,0 +1,110 @@
+// pkg/api/checklist.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+// maxChecklistTitle — наибольшая длина пункта чек-листа в символах.
+const maxChecklistTitle = 255
+
+type ChecklistResp struct {
+	Items []db.ChecklistItem `json:"items"`
+}
+
+func checkChecklistItem(item *db.ChecklistItem) error {
+	item.Title = strings.TrimSpace(item.Title)
+	if item.Title == "" {
+		return errors.New("title is empty")
+	}
+	if utf8.RuneCountInString(item.Title) > maxChecklistTitle {
+		return fmt.Errorf("пункт чек-листа длиннее %d символов", maxChecklistTitle)
+	}
+	if item.Position < 0 {
+		return errors.New("invalid position")
+	}
+	return nil
+}
+
+// checklistHandler управляет чек-листом задачи: GET ?task_id= — пункты
+// по порядку, POST — добавить пункт, PUT — изменить, DELETE ?id= — удалить.
+func (a *API) checklistHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		taskID, err := strconv.Atoi(r.FormValue("task_id"))
+		if err != nil {
+			writeJSONError(w, "invalid task_id", http.StatusBadRequest)
+			return
+		}
+		items, err := a.storeFor(r).Checklist(taskID)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, ChecklistResp{Items: items})
+
+	case http.MethodPost:
+		var item db.ChecklistItem
+		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+			writeJSONError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if item.TaskID == 0 {
+			writeJSONError(w, "task_id is empty", http.StatusBadRequest)
+			return
+		}
+		if err := checkChecklistItem(&item); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := a.storeFor(r).AddChecklistItem(item)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]string{"id": fmt.Sprint(id)})
+
+	case http.MethodPut:
+		var item db.ChecklistItem
+		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+			writeJSONError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if item.ID == 0 {
+			writeJSONError(w, "id is empty", http.StatusBadRequest)
+			return
+		}
+		if err := checkChecklistItem(&item); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.storeFor(r).UpdateChecklistItem(item); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{})
+
+	case http.MethodDelete:
+		id, err := requestID(r)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.storeFor(r).DeleteChecklistItem(id); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{})
+
+	default:
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
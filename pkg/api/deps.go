@@ -0,0 +1,94 @@
+// pkg/api/deps.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+type DependenciesResp struct {
+	Dependencies []db.Dependency `json:"dependencies"`
+}
+
+// requestBlockedFilter читает параметр blocked: hide — скрыть заблокированные
+// задачи, only — показать только их. Флаг blocked задачи приходят всегда.
+func requestBlockedFilter(r *http.Request, filter *db.TaskFilter) error {
+	var blocked bool
+	switch r.URL.Query().Get("blocked") {
+	case "", "show":
+		return nil
+	case "hide":
+		blocked = false
+	case "only":
+		blocked = true
+	default:
+		return errors.New("blocked принимает show, hide или only")
+	}
+	filter.Blocked = &blocked
+	return nil
+}
+
+// openDependencies возвращает названия невыполненных предварительных задач.
+func (a *API) openDependencies(r *http.Request, id int) ([]string, error) {
+	deps, err := a.storeFor(r).Dependencies(id)
+	if err != nil {
+		return nil, err
+	}
+	var titles []string
+	for _, d := range deps {
+		if d.Open {
+			titles = append(titles, d.Title)
+		}
+	}
+	return titles, nil
+}
+
+// depsHandler управляет зависимостями задачи: GET ?id= — список
+// предварительных задач, POST ?id=&depends_on= — добавить связь,
+// DELETE ?id=&depends_on= — удалить.
+func (a *API) depsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := requestID(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		deps, err := a.storeFor(r).Dependencies(id)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, DependenciesResp{Dependencies: deps})
+
+	case http.MethodPost, http.MethodDelete:
+		dependsOn, err := strconv.Atoi(r.FormValue("depends_on"))
+		if err != nil {
+			writeJSONError(w, "invalid depends_on", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPost {
+			err = a.storeFor(r).AddDependency(id, dependsOn)
+		} else {
+			err = a.storeFor(r).RemoveDependency(id, dependsOn)
+		}
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{})
+
+	default:
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// blockedMessage — текст отказа выполнить задачу с открытыми зависимостями.
+func blockedMessage(titles []string) string {
+	return "задача ждёт выполнения: " + strings.Join(titles, ", ")
+}
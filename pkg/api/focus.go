@@ -0,0 +1,42 @@
+// pkg/api/focus.go
+package api
+
+import (
+	"net/http"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+	"github.com/Myagchiev/final-project/pkg/utils"
+)
+
+// focusHandler возвращает задачи на сегодня и просроченные: сначала самые
+// важные, среди равных — более ранние. «Сегодня» считается в часовом поясе
+// запроса. Страницы задаются так же, как в /api/tasks, кроме sort.
+func (a *API) focusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now, err := currentTime(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := requestPage(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page.Sort = db.SortPriority
+
+	filter := db.TaskFilter{To: now.Format(utils.DateLayout)}
+	if err := requestTagFilter(r, &filter); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := requestProjectFilter(r, &filter); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.writeTasksPage(w, r, filter, page)
+}
@@ -0,0 +1,76 @@
+// pkg/api/history.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+type CompletionsResp struct {
+	Completions []db.Completion `json:"completions"`
+}
+
+// requestLimit читает параметр limit: по умолчанию и не больше maxTasks.
+func requestLimit(r *http.Request) (int, bool) {
+	limitStr := r.FormValue("limit")
+	if limitStr == "" {
+		return maxTasks, true
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxTasks {
+		return 0, false
+	}
+	return limit, true
+}
+
+func (a *API) taskHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idStr := r.FormValue("id")
+	if idStr == "" {
+		writeJSONError(w, "id is empty", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSONError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	limit, ok := requestLimit(r)
+	if !ok {
+		writeJSONError(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	completions, err := a.storeFor(r).TaskHistory(id, limit)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, CompletionsResp{Completions: completions})
+}
+
+func (a *API) completedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, ok := requestLimit(r)
+	if !ok {
+		writeJSONError(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	completions, err := a.storeFor(r).RecentCompletions(limit)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, CompletionsResp{Completions: completions})
+}
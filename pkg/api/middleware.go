@@ -2,20 +2,78 @@
 package api
 
 import (
+    "context"
+    "errors"
     "net/http"
     "os"
+    "strings"
 
+    "github.com/Myagchiev/final-project/pkg/db"
     "github.com/golang-jwt/jwt/v5"
 )
 
-func Auth(next http.HandlerFunc) http.HandlerFunc {
+type contextKey int
+
+const (
+    userKey contextKey = iota
+    claimsKey
+    apiKeyKey
+)
+
+// requestAPIKey возвращает ключ API, с которым пришёл запрос; nil, если
+// запрос пришёл с токеном из cookie.
+func requestAPIKey(r *http.Request) *db.APIKey {
+    key, _ := r.Context().Value(apiKeyKey).(*db.APIKey)
+    return key
+}
+
+// requestClaims возвращает токен запроса; nil, если вход не требуется.
+func requestClaims(r *http.Request) *Claims {
+    claims, _ := r.Context().Value(claimsKey).(*Claims)
+    return claims
+}
+
+// requestUser возвращает ID пользователя запроса; 0 — общий аккаунт.
+func requestUser(r *http.Request) int {
+    id, _ := r.Context().Value(userKey).(int)
+    return id
+}
+
+// storeFor возвращает хранилище, ограниченное данными пользователя запроса.
+func (a *API) storeFor(r *http.Request) db.TaskStore {
+    return a.store.ForUser(requestUser(r))
+}
+
+// authRequired сообщает, нужен ли вход: задан общий пароль или заведены
+// учётные записи.
+func (a *API) authRequired() (bool, error) {
+    if os.Getenv("TODO_PASSWORD") != "" {
+        return true, nil
+    }
+    return a.store.HasUsers()
+}
+
+// Auth проверяет ключ API из заголовка Authorization: Bearer или токен из
+// cookie и передаёт обработчику запрос с пользователем в контексте.
+func (a *API) Auth(next http.HandlerFunc) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        pass := os.Getenv("TODO_PASSWORD")
-        if pass == "" {
+        required, err := a.authRequired()
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        if !required {
             next(w, r)
             return
         }
 
+        // схема в Authorization не зависит от регистра (RFC 7235)
+        scheme, secret, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+        if strings.EqualFold(scheme, "Bearer") {
+            a.apiKeyAuth(next, w, r, strings.TrimSpace(secret))
+            return
+        }
+
         cookie, err := r.Cookie("token")
         if err != nil {
             http.Error(w, "Authentication required", http.StatusUnauthorized)
@@ -28,16 +86,54 @@ func Auth(next http.HandlerFunc) http.HandlerFunc {
             return jwtKey, nil
         })
 
-        if err != nil || !token.Valid {
+        if err != nil || !token.Valid || claims.ID == "" {
             http.Error(w, "Invalid token", http.StatusUnauthorized)
             return
         }
 
-        if hashPassword(pass) != claims.PasswordHash {
+        revoked, err := a.store.TokenRevoked(claims.ID)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        if revoked {
+            http.Error(w, "Token revoked", http.StatusUnauthorized)
+            return
+        }
+
+        // Смена пароля делает недействительными все выданные с ним токены.
+        current, err := a.passwordHash(claims.UserID)
+        if err != nil {
+            http.Error(w, "Invalid token", http.StatusUnauthorized)
+            return
+        }
+        if current != claims.PasswordHash {
             http.Error(w, "Password changed", http.StatusUnauthorized)
             return
         }
 
-        next(w, r)
+        ctx := context.WithValue(r.Context(), userKey, claims.UserID)
+        next(w, r.WithContext(context.WithValue(ctx, claimsKey, claims)))
+    }
+}
+
+// apiKeyAuth пропускает запрос с ключом API. Ключ только для чтения
+// разрешает лишь GET и HEAD.
+func (a *API) apiKeyAuth(next http.HandlerFunc, w http.ResponseWriter, r *http.Request, secret string) {
+    key, err := a.store.APIKeyBySecret(secret)
+    if errors.Is(err, db.ErrAPIKeyNotFound) {
+        http.Error(w, "Invalid API key", http.StatusUnauthorized)
+        return
     }
-}
\ No newline at end of file
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if key.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+        http.Error(w, "API key is read-only", http.StatusForbidden)
+        return
+    }
+
+    ctx := context.WithValue(r.Context(), userKey, key.UserID)
+    next(w, r.WithContext(context.WithValue(ctx, apiKeyKey, &key)))
+}
@@ -0,0 +1,206 @@
+// pkg/api/projects.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+// maxProjectName — наибольшая длина названия проекта в символах.
+const maxProjectName = 255
+
+var projectColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type ProjectsResp struct {
+	Projects []db.Project `json:"projects"`
+}
+
+func checkProject(p *db.Project) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("name is empty")
+	}
+	if utf8.RuneCountInString(p.Name) > maxProjectName {
+		return fmt.Errorf("название проекта длиннее %d символов", maxProjectName)
+	}
+	if p.Color != "" && !projectColor.MatchString(p.Color) {
+		return errors.New("цвет проекта задаётся как #RRGGBB")
+	}
+	return nil
+}
+
+// checkTaskProject проверяет, что в проект задачи можно добавлять задачи.
+func (a *API) checkTaskProject(r *http.Request, task *db.Task) error {
+	if task.ProjectID == 0 {
+		return nil
+	}
+	p, err := a.storeFor(r).GetProject(task.ProjectID)
+	if err != nil {
+		return err
+	}
+	if p.Archived {
+		return errors.New("проект в архиве")
+	}
+	return nil
+}
+
+// requestProjectFilter читает фильтр project=ID; project=0 — задачи без проекта.
+func requestProjectFilter(r *http.Request, filter *db.TaskFilter) error {
+	projectStr := r.URL.Query().Get("project")
+	if projectStr == "" {
+		return nil
+	}
+	id, err := strconv.Atoi(projectStr)
+	if err != nil || id < 0 {
+		return errors.New("invalid project")
+	}
+	filter.Project = &id
+	return nil
+}
+
+// projectsHandler возвращает проекты; архивные — с параметром archived=1.
+func (a *API) projectsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projects, err := a.storeFor(r).Projects(r.FormValue("archived") == "1")
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, ProjectsResp{Projects: projects})
+}
+
+func (a *API) projectHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.getProjectHandler(w, r)
+	case http.MethodPost:
+		a.addProjectHandler(w, r)
+	case http.MethodPut:
+		a.updateProjectHandler(w, r)
+	case http.MethodDelete:
+		a.deleteProjectHandler(w, r)
+	default:
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func requestID(r *http.Request) (int, error) {
+	idStr := r.FormValue("id")
+	if idStr == "" {
+		return 0, errors.New("id is empty")
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, errors.New("invalid id")
+	}
+	return id, nil
+}
+
+func (a *API) getProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := requestID(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, err := a.storeFor(r).GetProject(id)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, p)
+}
+
+func (a *API) addProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var p db.Project
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeJSONError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if err := checkProject(&p); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, err := a.storeFor(r).AddProject(p)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"id": fmt.Sprint(id)})
+}
+
+func (a *API) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var p db.Project
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeJSONError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if p.ID == 0 {
+		writeJSONError(w, "id is empty", http.StatusBadRequest)
+		return
+	}
+	if err := checkProject(&p); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.storeFor(r).UpdateProject(p); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{})
+}
+
+// deleteProjectHandler удаляет проект. Если в нём есть задачи, параметр tasks
+// обязателен: tasks=move переносит их в проект to (по умолчанию — без
+// проекта), tasks=delete удаляет их в корзину.
+func (a *API) deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := requestID(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var tasks db.ProjectTasks
+	switch r.FormValue("tasks") {
+	case "":
+		p, err := a.storeFor(r).GetProject(id)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p.Tasks > 0 {
+			writeJSONError(w, "в проекте есть задачи: укажите tasks=move или tasks=delete", http.StatusConflict)
+			return
+		}
+	case "move":
+		if toStr := r.FormValue("to"); toStr != "" {
+			tasks.MoveTo, err = strconv.Atoi(toStr)
+			if err != nil || tasks.MoveTo < 0 {
+				writeJSONError(w, "invalid to", http.StatusBadRequest)
+				return
+			}
+		}
+	case "delete":
+		tasks.Delete = true
+	default:
+		writeJSONError(w, "tasks принимает move или delete", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.storeFor(r).DeleteProject(id, tasks); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{})
+}
@@ -0,0 +1,131 @@
+// pkg/api/query.go
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+	"github.com/Myagchiev/final-project/pkg/utils"
+)
+
+// searchDateLayout — формат дат в строке поиска.
+const searchDateLayout = "02.01.2006"
+
+// parseSearchQuery разбирает строку поиска /api/tasks. Поддерживаются:
+//
+//	слово "фраза целиком"      — текст в названии или комментарии
+//	title:слово comment:"фраза" — текст в одном поле
+//	-слово -title:"фраза"        — исключить задачи с текстом
+//	from:01.03.2025 to:31.03.2025 — диапазон дат включительно
+//	repeat:yes repeat:no         — только повторяющиеся или однократные
+//	01.03.2025                   — задачи на дату
+func parseSearchQuery(query string) (db.TaskFilter, error) {
+	var filter db.TaskFilter
+
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var tok searchToken
+		tok, rest = nextSearchToken(rest)
+
+		switch tok.key {
+		case "":
+			if date, err := time.Parse(searchDateLayout, tok.value); err == nil && !tok.phrase && !tok.negate {
+				filter.Date = date.Format(utils.DateLayout)
+				continue
+			}
+			if tok.value != "" {
+				filter.Terms = append(filter.Terms, tok.term(""))
+			}
+
+		case "title", "comment":
+			if tok.value == "" {
+				return filter, fmt.Errorf("пустое условие %s:", tok.key)
+			}
+			filter.Terms = append(filter.Terms, tok.term(tok.key))
+
+		case "from", "to":
+			if tok.negate {
+				return filter, fmt.Errorf("условие %s: нельзя отрицать", tok.key)
+			}
+			date, err := time.Parse(searchDateLayout, tok.value)
+			if err != nil {
+				return filter, fmt.Errorf("неверная дата в %s:, ожидается ДД.ММ.ГГГГ", tok.key)
+			}
+			if tok.key == "from" {
+				filter.From = date.Format(utils.DateLayout)
+			} else {
+				filter.To = date.Format(utils.DateLayout)
+			}
+
+		case "repeat":
+			var repeat bool
+			switch tok.value {
+			case "yes":
+				repeat = true
+			case "no":
+				repeat = false
+			default:
+				return filter, fmt.Errorf("repeat: принимает yes или no")
+			}
+			if tok.negate {
+				repeat = !repeat
+			}
+			filter.Repeat = &repeat
+		}
+	}
+	return filter, nil
+}
+
+// searchKeys — ключи условий; другие слова с двоеточием, например «18:00»,
+// считаются обычным текстом.
+var searchKeys = []string{"title", "comment", "from", "to", "repeat"}
+
+type searchToken struct {
+	key    string
+	value  string
+	phrase bool
+	negate bool
+}
+
+func (t searchToken) term(field string) db.SearchTerm {
+	return db.SearchTerm{Field: field, Text: t.value, Phrase: t.phrase, Negate: t.negate}
+}
+
+// nextSearchToken отделяет от начала s одно условие и возвращает остаток строки.
+func nextSearchToken(s string) (searchToken, string) {
+	var tok searchToken
+
+	if len(s) > 1 && s[0] == '-' && !unicode.IsSpace(rune(s[1])) {
+		tok.negate = true
+		s = s[1:]
+	}
+	for _, key := range searchKeys {
+		if strings.HasPrefix(s, key+":") {
+			tok.key = key
+			s = s[len(key)+1:]
+			break
+		}
+	}
+
+	if strings.HasPrefix(s, `"`) {
+		tok.phrase = true
+		s = s[1:]
+		end := strings.IndexByte(s, '"')
+		if end < 0 {
+			end = len(s)
+		}
+		tok.value = strings.TrimSpace(s[:end])
+		s = s[min(end+1, len(s)):]
+	} else {
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		tok.value = s[:end]
+		s = s[end:]
+	}
+	return tok, strings.TrimLeftFunc(s, unicode.IsSpace)
+}
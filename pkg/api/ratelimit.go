@@ -0,0 +1,159 @@
+// pkg/api/ratelimit.go
+package api
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// freeAttempts — неудачные попытки входа с одного адреса без задержки.
+	freeAttempts = 3
+	// baseBackoff удваивается с каждой следующей неудачей до maxBackoff;
+	// достигнув его, адрес считается заблокированным.
+	baseBackoff = time.Second
+	maxBackoff  = 15 * time.Minute
+	// failureMemory — через сколько после последней неудачи счётчик адреса
+	// обнуляется.
+	failureMemory = time.Hour
+
+	// За globalWindow допускается не больше globalFailures неудач со всех
+	// адресов; затем вход закрыт для всех до конца окна.
+	globalFailures = 100
+	globalWindow   = time.Minute
+
+	// maxTrackedIPs — сколько адресов limiter помнит одновременно.
+	maxTrackedIPs = 10000
+)
+
+type ipAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// loginLimiter ограничивает попытки входа: с одного адреса — с
+// экспоненциальной задержкой после freeAttempts неудач, со всех адресов —
+// не больше globalFailures неудач за globalWindow.
+type loginLimiter struct {
+	mu          sync.Mutex
+	ips         map[string]*ipAttempts
+	windowStart time.Time
+	windowCount int
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{ips: make(map[string]*ipAttempts)}
+}
+
+// allow проверяет, можно ли сейчас попытаться войти с адреса ip, и сразу
+// резервирует попытку, учитывая её как неудачную: иначе одновременные
+// попытки прошли бы проверку, пока сравнивается пароль первой. Удачный вход
+// снимает резерв через succeed.
+//
+// wait > 0 — через сколько можно повторить попытку; попытка не учитывается.
+// Иначе backoff — задержка, которая начнёт действовать, если попытка
+// окажется неудачной.
+func (l *loginLimiter) allow(ip string) (wait, backoff time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.windowStart) >= globalWindow {
+		l.windowStart = now
+		l.windowCount = 0
+	}
+	if l.windowCount >= globalFailures {
+		wait = l.windowStart.Add(globalWindow).Sub(now)
+	}
+	a := l.ips[ip]
+	if a != nil {
+		if d := a.blockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return wait, 0
+	}
+
+	l.windowCount++
+	if a == nil || now.Sub(a.lastFailure) >= failureMemory {
+		l.makeRoom(now)
+		a = &ipAttempts{}
+		l.ips[ip] = a
+	}
+	a.failures++
+	a.lastFailure = now
+	if a.failures <= freeAttempts {
+		return 0, 0
+	}
+
+	backoff = maxBackoff
+	if exp := a.failures - freeAttempts - 1; exp < 20 {
+		backoff = min(baseBackoff<<exp, maxBackoff)
+	}
+	a.blockedUntil = now.Add(backoff)
+	return 0, backoff
+}
+
+// succeed снимает резерв удачной попытки и сбрасывает счётчик адреса.
+func (l *loginLimiter) succeed(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.ips, ip)
+	// если окно успело смениться, резерв остаётся в прошлом окне
+	if l.windowCount > 0 {
+		l.windowCount--
+	}
+}
+
+// makeRoom освобождает место для нового адреса: сначала удаляет забытые
+// адреса, а если их нет — адрес с самой давней неудачей.
+func (l *loginLimiter) makeRoom(now time.Time) {
+	if len(l.ips) < maxTrackedIPs {
+		return
+	}
+	l.sweep(now)
+	for len(l.ips) >= maxTrackedIPs {
+		var oldest string
+		for ip, a := range l.ips {
+			if oldest == "" || a.lastFailure.Before(l.ips[oldest].lastFailure) {
+				oldest = ip
+			}
+		}
+		delete(l.ips, oldest)
+	}
+}
+
+// sweep удаляет адреса, неудачи которых уже забыты.
+func (l *loginLimiter) sweep(now time.Time) {
+	for ip, a := range l.ips {
+		if now.Sub(a.lastFailure) >= failureMemory && now.After(a.blockedUntil) {
+			delete(l.ips, ip)
+		}
+	}
+}
+
+// setRetryAfter ставит заголовок Retry-After в целых секундах и возвращает их.
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return seconds
+}
+
+// writeTooManyAttempts отвечает 429 с заголовком Retry-After.
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := setRetryAfter(w, wait)
+	writeJSONError(w, "слишком много попыток входа, повторите через "+strconv.Itoa(seconds)+" с", http.StatusTooManyRequests)
+}
+
+// logFailedSignIn пишет строку для fail2ban; подходит failregex
+// `failed signin from <HOST> `. Попытки, отклонённые без проверки пароля,
+// пишутся так же.
+func logFailedSignIn(ip, login, reason string) {
+	log.Printf("failed signin from %s login=%q: %s", ip, login, reason)
+}
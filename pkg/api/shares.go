@@ -0,0 +1,128 @@
+// pkg/api/shares.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+type SharesResp struct {
+	Shares []db.Share `json:"shares"`
+}
+
+// roleOwner — роль владельца задачи в requireTaskAccess.
+const roleOwner = "owner"
+
+// roleRank упорядочивает роли по возрастанию прав.
+var roleRank = map[string]int{db.RoleViewer: 1, db.RoleEditor: 2, roleOwner: 3}
+
+// requireTaskAccess проверяет, что пользователь запроса имеет на задачу id
+// права не ниже need, и возвращает задачу. Иначе отвечает ошибкой сам.
+func (a *API) requireTaskAccess(w http.ResponseWriter, r *http.Request, id int, need string) (db.Task, bool) {
+	task, err := a.storeFor(r).GetTask(id)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return task, false
+	}
+	role := task.Shared
+	if role == "" {
+		role = roleOwner
+	}
+	if roleRank[role] < roleRank[need] {
+		writeJSONError(w, db.ErrForbidden.Error(), http.StatusForbidden)
+		return task, false
+	}
+	return task, true
+}
+
+// requestSharedFilter читает параметр shared: hide — только свои задачи,
+// only — только открытые пользователю чужие.
+func requestSharedFilter(r *http.Request, filter *db.TaskFilter) error {
+	var shared bool
+	switch r.URL.Query().Get("shared") {
+	case "", "show":
+		return nil
+	case "hide":
+		shared = false
+	case "only":
+		shared = true
+	default:
+		return errors.New("shared принимает show, hide или only")
+	}
+	filter.Shared = &shared
+	return nil
+}
+
+// shareHandler управляет доступом к задачам и проектам: GET ?task_id= или
+// ?project_id= — кому открыт доступ, POST — открыть доступ пользователю
+// login с ролью viewer или editor, DELETE ?id= — закрыть.
+func (a *API) shareHandler(w http.ResponseWriter, r *http.Request) {
+	store := a.storeFor(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		var taskID, projectID int
+		for name, dest := range map[string]*int{"task_id": &taskID, "project_id": &projectID} {
+			value := r.FormValue(name)
+			if value == "" {
+				continue
+			}
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				writeJSONError(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*dest = id
+		}
+		shares, err := store.Shares(taskID, projectID)
+		if err != nil {
+			writeJSONError(w, err.Error(), shareErrorStatus(err))
+			return
+		}
+		writeJSON(w, SharesResp{Shares: shares})
+
+	case http.MethodPost:
+		var share db.Share
+		if err := json.NewDecoder(r.Body).Decode(&share); err != nil {
+			writeJSONError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if share.Login == "" {
+			writeJSONError(w, "login is empty", http.StatusBadRequest)
+			return
+		}
+		id, err := store.AddShare(share)
+		if err != nil {
+			writeJSONError(w, err.Error(), shareErrorStatus(err))
+			return
+		}
+		writeJSON(w, map[string]string{"id": fmt.Sprint(id)})
+
+	case http.MethodDelete:
+		id, err := requestID(r)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := store.DeleteShare(id); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{})
+
+	default:
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func shareErrorStatus(err error) int {
+	if errors.Is(err, db.ErrForbidden) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,135 @@
+// pkg/api/tags.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+// maxTagLength — наибольшая длина метки в символах.
+const maxTagLength = 64
+
+type TagsResp struct {
+	Tags []db.Tag `json:"tags"`
+}
+
+// normalizeTag приводит метку к нижнему регистру и проверяет её. Запятая
+// недопустима: через неё перечисляются метки в фильтре /api/tasks.
+func normalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", errors.New("пустая метка")
+	}
+	if utf8.RuneCountInString(name) > maxTagLength {
+		return "", fmt.Errorf("метка длиннее %d символов", maxTagLength)
+	}
+	if strings.Contains(name, ",") {
+		return "", errors.New("метка не может содержать запятую")
+	}
+	return name, nil
+}
+
+// checkTags нормализует метки задачи и убирает повторы.
+func checkTags(task *db.Task) error {
+	if task.Tags == nil {
+		return nil
+	}
+	tags := make([]string, 0, len(task.Tags))
+	seen := make(map[string]bool)
+	for _, name := range task.Tags {
+		tag, err := normalizeTag(name)
+		if err != nil {
+			return err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	task.Tags = tags
+	return nil
+}
+
+// requestTagFilter читает фильтр по меткам: tags=a,b и tags_mode=all|any.
+func requestTagFilter(r *http.Request, filter *db.TaskFilter) error {
+	tagsStr := r.URL.Query().Get("tags")
+	if tagsStr == "" {
+		return nil
+	}
+	for _, name := range strings.Split(tagsStr, ",") {
+		tag, err := normalizeTag(name)
+		if err != nil {
+			return err
+		}
+		filter.Tags = append(filter.Tags, tag)
+	}
+	switch r.URL.Query().Get("tags_mode") {
+	case "", "all":
+	case "any":
+		filter.AnyTag = true
+	default:
+		return errors.New("tags_mode принимает all или any")
+	}
+	return nil
+}
+
+func (a *API) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tags, err := a.storeFor(r).Tags()
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, TagsResp{Tags: tags})
+}
+
+// tagHandler переименовывает (PUT с {"name": "новое"}) или удаляет (DELETE)
+// метку name.
+func (a *API) tagHandler(w http.ResponseWriter, r *http.Request) {
+	name, err := normalizeTag(r.FormValue("name"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		newName, err := normalizeTag(req.Name)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.storeFor(r).RenameTag(name, newName); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+	case http.MethodDelete:
+		if err := a.storeFor(r).DeleteTag(name); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+	default:
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]interface{}{})
+}
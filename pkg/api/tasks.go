@@ -3,38 +3,46 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/Myagchiev/final-project/pkg/db"
 	"github.com/Myagchiev/final-project/pkg/utils"
 )
 
-const maxTasks = 50
+const (
+	maxTasks = 50
+	// maxDuration — наибольшая длительность задачи в минутах
+	maxDuration = 24 * 60
+)
 
 type TasksResp struct {
 	Tasks []db.Task `json:"tasks"`
+	// Next — курсор следующей страницы для параметра cursor; нет — страница последняя.
+	Next string `json:"next,omitempty"`
 }
 
-func checkAndFixDate(task *db.Task) error {
-	now := time.Now()
+// checkAndFixDate переносит прошедшую дату задачи на сегодня или на следующее
+// повторение. «Сегодня» считается в часовом поясе now.
+func checkAndFixDate(task *db.Task, now time.Time) error {
 	today := now.Format(utils.DateLayout)
-	truncatedNow := now.Truncate(24 * time.Hour)
 
 	if task.Date == "" {
 		task.Date = today
-		return nil
 	}
 
-	parsed, err := time.Parse(utils.DateLayout, task.Date)
-	if err != nil {
+	if _, err := time.Parse(utils.DateLayout, task.Date); err != nil {
 		return err
 	}
 
-	if !parsed.Before(truncatedNow) {
+	if task.Date >= today {
+		if task.RepeatUntil != "" && task.Date > task.RepeatUntil {
+			return errors.New("дата задачи позже даты окончания повторений")
+		}
 		return nil
 	}
 
@@ -43,54 +51,135 @@ func checkAndFixDate(task *db.Task) error {
 		return nil
 	}
 
-	next, err := utils.NextDate(now, task.Date, task.Repeat)
+	next, end, err := utils.NextDateEnd(now, task.Date, task.Time, task.Repeat, task.RepeatEnd())
 	if err != nil {
 		return err
 	}
 	task.Date = next
+	task.RepeatCount = end.Count
+	return nil
+}
+
+// checkRepeat проверяет правило повторения и условие окончания серии.
+// COUNT и UNTIL из RRULE переносятся в поля задачи.
+func checkRepeat(task *db.Task) error {
+	if err := utils.ValidateRepeat(task.Repeat); err != nil {
+		return err
+	}
+	if task.Repeat == "" {
+		task.RepeatCount = 0
+		task.RepeatUntil = ""
+		return nil
+	}
+
+	repeat, end, err := utils.SplitRRuleEnd(task.Repeat)
+	if err != nil {
+		return err
+	}
+	if end != (utils.RepeatEnd{}) {
+		if task.RepeatCount != 0 || task.RepeatUntil != "" {
+			return errors.New("условие окончания повторений задано дважды")
+		}
+		task.Repeat = repeat
+		task.RepeatCount = end.Count
+		task.RepeatUntil = end.Until
+	}
+	return utils.ValidateRepeatEnd(task.RepeatEnd())
+}
+
+// checkTime проверяет время начала и длительность задачи.
+func checkTime(task *db.Task) error {
+	if _, err := utils.ParseTimeOfDay(task.Time); err != nil {
+		return err
+	}
+	if task.Duration < 0 || task.Duration > maxDuration {
+		return fmt.Errorf("длительность должна быть от 0 до %d минут", maxDuration)
+	}
+	if task.Duration > 0 && task.Time == "" {
+		return errors.New("длительность задаётся только вместе со временем начала")
+	}
 	return nil
 }
 
-func tasksListHandler(w http.ResponseWriter, r *http.Request) {
+// checkPriority проверяет важность задачи.
+func checkPriority(task *db.Task) error {
+	if task.Priority < 0 || task.Priority > db.MaxPriority {
+		return fmt.Errorf("важность задачи должна быть от 0 до %d", db.MaxPriority)
+	}
+	return nil
+}
+
+func (a *API) tasksListHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	search := strings.TrimSpace(r.URL.Query().Get("search"))
-	tasks := make([]db.Task, 0, maxTasks)
-	var err error
-
-	if search == "" {
-		tasks, err = db.Tasks(maxTasks)
-		if err != nil {
-			writeJSONError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		writeJSON(w, TasksResp{Tasks: tasks})
+	filter, err := parseSearchQuery(r.URL.Query().Get("search"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := requestTagFilter(r, &filter); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := requestProjectFilter(r, &filter); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := requestBlockedFilter(r, &filter); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := requestSharedFilter(r, &filter); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if t, parseErr := time.Parse("02.01.2006", search); parseErr == nil {
-		searchDate := t.Format(utils.DateLayout)
-		tasks, err = db.TasksWithFilter(maxTasks, "", searchDate)
-		if err != nil {
-			writeJSONError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		writeJSON(w, TasksResp{Tasks: tasks})
+	page, err := requestPage(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	a.writeTasksPage(w, r, filter, page)
+}
+
+// requestPage читает параметры страницы списка: limit, sort, order и cursor.
+func requestPage(r *http.Request) (db.Page, error) {
+	limit, ok := requestLimit(r)
+	if !ok {
+		return db.Page{}, errors.New("invalid limit")
+	}
+	page := db.Page{
+		Limit:  limit,
+		Sort:   r.URL.Query().Get("sort"),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+	switch r.URL.Query().Get("order") {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, errors.New("order принимает asc или desc")
+	}
+	return page, nil
+}
 
-	tasks, err = db.TasksWithFilter(maxTasks, search, "")
+func (a *API) writeTasksPage(w http.ResponseWriter, r *http.Request, filter db.TaskFilter, page db.Page) {
+	tasks, next, err := a.storeFor(r).TasksWithFilter(filter, page)
+	if errors.Is(err, db.ErrInvalidPage) {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	writeJSON(w, TasksResp{Tasks: tasks})
+	writeJSON(w, TasksResp{Tasks: tasks, Next: next})
 }
 
-func addTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -105,12 +194,37 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, "title is empty", http.StatusBadRequest)
 		return
 	}
-	if err := checkAndFixDate(&task); err != nil {
+	if err := checkRepeat(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkTime(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkTags(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkPriority(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.checkTaskProject(r, &task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now, err := currentTime(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkAndFixDate(&task, now); err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id, err := db.AddTask(task)
+	id, err := a.storeFor(r).AddTask(task)
 	if err != nil {
 		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -118,7 +232,7 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]string{"id": fmt.Sprint(id)})
 }
 
-func getTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -135,7 +249,7 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := db.GetTask(id)
+	task, err := a.storeFor(r).GetTask(id)
 	if err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -143,14 +257,26 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, task)
 }
 
-func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var task db.Task
-	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var (
+		task db.Task
+		sent map[string]json.RawMessage
+	)
+	if err := json.Unmarshal(body, &task); err != nil {
+		writeJSONError(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(body, &sent); err != nil {
 		writeJSONError(w, "invalid json", http.StatusBadRequest)
 		return
 	}
@@ -158,22 +284,80 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, "id is empty", http.StatusBadRequest)
 		return
 	}
+	old, ok := a.requireTaskAccess(w, r, task.ID, db.RoleEditor)
+	if !ok {
+		return
+	}
+	keepOmittedFields(&task, old, sent)
 	if task.Title == "" {
 		writeJSONError(w, "title is empty", http.StatusBadRequest)
 		return
 	}
-	if err := checkAndFixDate(&task); err != nil {
+	if err := checkRepeat(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkTime(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkTags(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkPriority(&task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Проект чужой задачи меняет только владелец.
+	if old.Shared != "" {
+		task.ProjectID = old.ProjectID
+	} else if err := a.checkTaskProject(r, &task); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now, err := currentTime(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkAndFixDate(&task, now); err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.UpdateTask(task); err != nil {
+	if err := a.storeFor(r).UpdateTask(task); err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	writeJSON(w, map[string]interface{}{})
 }
 
-func doneTaskHandler(w http.ResponseWriter, r *http.Request) {
+// keepOmittedFields переносит из old поля, которых нет в запросе sent:
+// клиент, который о них не знает (например, web/), не должен их сбрасывать.
+func keepOmittedFields(task *db.Task, old db.Task, sent map[string]json.RawMessage) {
+	// COUNT и UNTIL в самом правиле RRULE заменяют прежнее окончание серии
+	if _, end, err := utils.SplitRRuleEnd(task.Repeat); err == nil && end == (utils.RepeatEnd{}) {
+		keepOmitted(sent, "repeat_count", &task.RepeatCount, old.RepeatCount)
+		keepOmitted(sent, "repeat_until", &task.RepeatUntil, old.RepeatUntil)
+	}
+	keepOmitted(sent, "time", &task.Time, old.Time)
+	// без времени начала длительность не задаётся
+	if task.Time != "" {
+		keepOmitted(sent, "duration", &task.Duration, old.Duration)
+	}
+	keepOmitted(sent, "priority", &task.Priority, old.Priority)
+	keepOmitted(sent, "project_id", &task.ProjectID, old.ProjectID)
+}
+
+// keepOmitted возвращает полю field прежнее значение old, если поля name
+// нет в запросе.
+func keepOmitted[T any](sent map[string]json.RawMessage, name string, field *T, old T) {
+	if _, ok := sent[name]; !ok {
+		*field = old
+	}
+}
+
+func (a *API) doneTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -189,14 +373,33 @@ func doneTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, "invalid id", http.StatusBadRequest)
 		return
 	}
-	if err := db.MarkDone(id); err != nil {
+	if _, ok := a.requireTaskAccess(w, r, id, db.RoleEditor); !ok {
+		return
+	}
+	now, err := currentTime(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Задачу с невыполненными предварительными задачами выполняют только
+	// явно, с параметром force=1.
+	open, err := a.openDependencies(r, id)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(open) > 0 && r.FormValue("force") != "1" {
+		writeJSONError(w, blockedMessage(open), http.StatusConflict)
+		return
+	}
+	if err := a.storeFor(r).MarkDone(id, now); err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	writeJSON(w, map[string]interface{}{})
 }
 
-func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -212,42 +415,45 @@ func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, "invalid id", http.StatusBadRequest)
 		return
 	}
-	if err := db.DeleteTask(id); err != nil {
+	if _, ok := a.requireTaskAccess(w, r, id, roleOwner); !ok {
+		return
+	}
+	if err := a.storeFor(r).DeleteTask(id); err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	writeJSON(w, map[string]interface{}{})
 }
 
-func taskCRUDHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) taskCRUDHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
 		switch r.URL.Path {
 		case "/api/task":
-			addTaskHandler(w, r)
+			a.addTaskHandler(w, r)
 		case "/api/task/done":
-			doneTaskHandler(w, r)
+			a.doneTaskHandler(w, r)
 		default:
 			writeJSONError(w, "not found", http.StatusNotFound)
 		}
 
 	case http.MethodGet:
 		if r.URL.Path == "/api/task" {
-			getTaskHandler(w, r)
+			a.getTaskHandler(w, r)
 			return
 		}
 		writeJSONError(w, "not found", http.StatusNotFound)
 
 	case http.MethodPut:
 		if r.URL.Path == "/api/task" {
-			updateTaskHandler(w, r)
+			a.updateTaskHandler(w, r)
 			return
 		}
 		writeJSONError(w, "not found", http.StatusNotFound)
 
 	case http.MethodDelete:
 		if r.URL.Path == "/api/task" {
-			deleteTaskHandler(w, r)
+			a.deleteTaskHandler(w, r)
 			return
 		}
 		writeJSONError(w, "not found", http.StatusNotFound)
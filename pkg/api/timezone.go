@@ -0,0 +1,70 @@
+// pkg/api/timezone.go
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Myagchiev/final-project/pkg/utils"
+)
+
+// timezoneHeader позволяет клиенту указать свой часовой пояс для расчёта «сегодня».
+const timezoneHeader = "X-Timezone"
+
+var serverLocation = time.Local
+
+func init() {
+	name := os.Getenv("TODO_TZ")
+	if name == "" {
+		return
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Printf("WARNING: unknown TODO_TZ %q – using local time zone\n", name)
+		return
+	}
+	serverLocation = loc
+}
+
+// requestLocation возвращает часовой пояс запроса: заголовок X-Timezone,
+// параметр tz или часовой пояс сервера.
+func requestLocation(r *http.Request) (*time.Location, error) {
+	name := r.Header.Get(timezoneHeader)
+	if name == "" {
+		name = r.URL.Query().Get("tz")
+	}
+	if name == "" {
+		return serverLocation, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс %q", name)
+	}
+	return loc, nil
+}
+
+// currentTime возвращает текущий момент в часовом поясе запроса.
+func currentTime(r *http.Request) (time.Time, error) {
+	loc, err := requestLocation(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(loc), nil
+}
+
+// requestNow работает как currentTime, но позволяет подменить дату
+// параметром now в формате utils.DateLayout.
+func requestNow(r *http.Request) (time.Time, error) {
+	now, err := currentTime(r)
+	if err != nil {
+		return now, err
+	}
+	if nowStr := r.FormValue("now"); nowStr != "" {
+		if parsed, err := time.ParseInLocation(utils.DateLayout, nowStr, now.Location()); err == nil {
+			now = parsed
+		}
+	}
+	return now, nil
+}
@@ -0,0 +1,58 @@
+// pkg/api/trash.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Myagchiev/final-project/pkg/db"
+)
+
+type TrashResp struct {
+	Items []db.TrashItem `json:"items"`
+}
+
+func (a *API) trashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, ok := requestLimit(r)
+	if !ok {
+		writeJSONError(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	items, err := a.storeFor(r).Trash(limit)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, TrashResp{Items: items})
+}
+
+func (a *API) restoreTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idStr := r.FormValue("id")
+	if idStr == "" {
+		writeJSONError(w, "id is empty", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSONError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	task, err := a.storeFor(r).RestoreTask(id)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, task)
+}
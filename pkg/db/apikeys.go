@@ -0,0 +1,89 @@
+// pkg/db/apikeys.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// APIKey — долгоживущий ключ для скриптов. Сам ключ показывается один раз
+// при создании, в базе хранится только его хеш.
+type APIKey struct {
+	ID     int    `json:"id,string"`
+	UserID int    `json:"-"`
+	Name   string `json:"name"`
+	// Prefix — начало ключа, по которому его можно узнать в списке.
+	Prefix string `json:"prefix"`
+	// ReadOnly — ключ разрешает только чтение.
+	ReadOnly   bool   `json:"read_only"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+const apiKeyColumns = "id, user_id, name, prefix, read_only, created_at, last_used_at"
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.ReadOnly, &k.CreatedAt, &k.LastUsedAt)
+	return k, err
+}
+
+// AddAPIKey сохраняет ключ secret пользователя и возвращает ID записи.
+func (s *SQLStore) AddAPIKey(key APIKey, secret string) (int, error) {
+	var id int
+	err := s.conn().QueryRow(
+		"INSERT INTO api_keys (user_id, name, prefix, key_hash, read_only, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		s.owner, key.Name, key.Prefix, tokenHash(secret), key.ReadOnly, formatTime(time.Now()),
+	).Scan(&id)
+	return id, err
+}
+
+// APIKeys возвращает ключи пользователя, начиная с новых.
+func (s *SQLStore) APIKeys() ([]APIKey, error) {
+	rows, err := s.conn().Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY id DESC", s.owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey удаляет ключ пользователя; запросы с ним сразу перестают
+// проходить.
+func (s *SQLStore) RevokeAPIKey(id int) error {
+	res, err := s.conn().Exec("DELETE FROM api_keys WHERE id = ? AND user_id = ?", id, s.owner)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// APIKeyBySecret находит ключ по его значению и отмечает время использования.
+func (s *SQLStore) APIKeyBySecret(secret string) (APIKey, error) {
+	hash := tokenHash(secret)
+	k, err := scanAPIKey(s.conn().QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", hash))
+	if err == sql.ErrNoRows {
+		return k, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return k, err
+	}
+	k.LastUsedAt = formatTime(time.Now())
+	_, err = s.conn().Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", k.LastUsedAt, k.ID)
+	return k, err
+}
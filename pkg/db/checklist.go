@@ -0,0 +1,210 @@
+// pkg/db/checklist.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ChecklistItem — пункт чек-листа задачи. Пункты задачи пронумерованы
+// по Position с единицы без пропусков.
+type ChecklistItem struct {
+	ID       int    `json:"id,string"`
+	TaskID   int    `json:"task_id,string"`
+	Title    string `json:"title"`
+	Position int    `json:"position,string"`
+	Done     bool   `json:"done"`
+}
+
+var errChecklistItemNotFound = errors.New("checklist item not found")
+
+const checklistColumns = "id, task_id, title, position, done"
+
+func scanChecklistItem(row rowScanner) (ChecklistItem, error) {
+	var item ChecklistItem
+	err := row.Scan(&item.ID, &item.TaskID, &item.Title, &item.Position, &item.Done)
+	return item, err
+}
+
+func (s *SQLStore) Checklist(taskID int) ([]ChecklistItem, error) {
+	if _, err := s.getTask(s.conn(), taskID); err != nil {
+		return nil, err
+	}
+	return checklist(s.conn(), taskID)
+}
+
+func checklist(q querier, taskID int) ([]ChecklistItem, error) {
+	rows, err := q.Query("SELECT "+checklistColumns+" FROM checklist WHERE task_id = ? ORDER BY position", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ChecklistItem{}
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// getChecklistItem читает пункт чек-листа задачи, которую пользователь может
+// менять.
+func (s *SQLStore) getChecklistItem(q querier, id int) (ChecklistItem, error) {
+	item, err := scanChecklistItem(q.QueryRow("SELECT "+checklistColumns+" FROM checklist WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return item, errChecklistItemNotFound
+	}
+	if err != nil {
+		return item, err
+	}
+	if _, err := s.editTask(q, item.TaskID); err == ErrForbidden {
+		return ChecklistItem{}, err
+	} else if err != nil {
+		return ChecklistItem{}, errChecklistItemNotFound
+	}
+	return item, nil
+}
+
+// clampPosition приводит желаемую позицию к 1..last; 0 означает «в конец».
+func clampPosition(position, last int) int {
+	if position <= 0 || position > last {
+		return last
+	}
+	return position
+}
+
+// AddChecklistItem добавляет пункт на позицию item.Position, сдвигая
+// следующие пункты; без позиции пункт добавляется в конец.
+func (s *SQLStore) AddChecklistItem(item ChecklistItem) (int, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := s.editTask(tx, item.TaskID); err != nil {
+		return 0, err
+	}
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM checklist WHERE task_id = ?", item.TaskID).Scan(&count); err != nil {
+		return 0, err
+	}
+	position := clampPosition(item.Position, count+1)
+	_, err = tx.Exec(
+		"UPDATE checklist SET position = position + 1 WHERE task_id = ? AND position >= ?",
+		item.TaskID, position,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow(
+		"INSERT INTO checklist (task_id, title, position, done) VALUES (?, ?, ?, ?) RETURNING id",
+		item.TaskID, item.Title, position, item.Done,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// UpdateChecklistItem меняет название, отметку и позицию пункта; Position = 0
+// оставляет пункт на месте. Задача пункта не меняется.
+func (s *SQLStore) UpdateChecklistItem(item ChecklistItem) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := s.getChecklistItem(tx, item.ID)
+	if err != nil {
+		return err
+	}
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM checklist WHERE task_id = ?", old.TaskID).Scan(&count); err != nil {
+		return err
+	}
+	position := old.Position
+	if item.Position != 0 {
+		position = clampPosition(item.Position, count)
+	}
+
+	switch {
+	case position < old.Position:
+		_, err = tx.Exec(
+			"UPDATE checklist SET position = position + 1 WHERE task_id = ? AND position >= ? AND position < ?",
+			old.TaskID, position, old.Position,
+		)
+	case position > old.Position:
+		_, err = tx.Exec(
+			"UPDATE checklist SET position = position - 1 WHERE task_id = ? AND position > ? AND position <= ?",
+			old.TaskID, old.Position, position,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE checklist SET title = ?, position = ?, done = ? WHERE id = ?",
+		item.Title, position, item.Done, item.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) DeleteChecklistItem(id int) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	item, err := s.getChecklistItem(tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM checklist WHERE id = ?", id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"UPDATE checklist SET position = position - 1 WHERE task_id = ? AND position > ?",
+		item.TaskID, item.Position,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setChecklist заменяет чек-лист задачи пунктами из снимка, сохраняя их id.
+func setChecklist(q querier, taskID int, items []ChecklistItem) error {
+	if _, err := q.Exec("DELETE FROM checklist WHERE task_id = ?", taskID); err != nil {
+		return err
+	}
+	for _, item := range items {
+		_, err := q.Exec(
+			"INSERT INTO checklist ("+checklistColumns+") VALUES (?, ?, ?, ?, ?)",
+			item.ID, taskID, item.Title, item.Position, item.Done,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetChecklist снимает отметки с пунктов, когда повторяющаяся задача
+// переходит на следующую дату.
+func resetChecklist(q querier, taskID int) error {
+	_, err := q.Exec("UPDATE checklist SET done = ? WHERE task_id = ?", false, taskID)
+	return err
+}
@@ -0,0 +1,69 @@
+// pkg/db/completion.go
+package db
+
+import (
+	"time"
+)
+
+// Completion — запись журнала выполнения задачи.
+type Completion struct {
+	ID     int    `json:"id,string"`
+	TaskID int    `json:"task_id,string"`
+	Title  string `json:"title"`
+	Date   string `json:"date"`
+	DoneAt string `json:"done_at"`
+}
+
+const completionColumns = "id, task_id, title, date, done_at"
+
+func addCompletion(q querier, task Task, now time.Time) (int, error) {
+	var id int
+	err := q.QueryRow(
+		"INSERT INTO completions (task_id, title, date, done_at, owner_id) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		task.ID, task.Title, task.Date, now.UTC().Format(time.RFC3339), task.OwnerID,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// TaskHistory возвращает журнал выполнения задачи, начиная с последних записей.
+// Журнал открытой пользователю чужой задачи виден, пока задача существует.
+func (s *SQLStore) TaskHistory(taskID int, limit int) ([]Completion, error) {
+	owner := s.owner
+	if t, err := s.getTask(s.conn(), taskID); err == nil {
+		owner = t.OwnerID
+	}
+	return s.queryCompletions(
+		"SELECT "+completionColumns+" FROM completions WHERE task_id = ? AND owner_id = ?"+
+			" ORDER BY done_at DESC, id DESC LIMIT ?",
+		taskID, owner, limit,
+	)
+}
+
+// RecentCompletions возвращает последние выполненные задачи.
+func (s *SQLStore) RecentCompletions(limit int) ([]Completion, error) {
+	return s.queryCompletions(
+		"SELECT "+completionColumns+" FROM completions WHERE owner_id = ? ORDER BY done_at DESC, id DESC LIMIT ?",
+		s.owner, limit,
+	)
+}
+
+func (s *SQLStore) queryCompletions(query string, args ...interface{}) ([]Completion, error) {
+	rows, err := s.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completions := []Completion{}
+	for rows.Next() {
+		var c Completion
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Title, &c.Date, &c.DoneAt); err != nil {
+			return nil, err
+		}
+		completions = append(completions, c)
+	}
+	return completions, rows.Err()
+}
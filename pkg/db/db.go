@@ -5,45 +5,81 @@ package db
 import (
     "database/sql"
     "log"
-    "os"
+    "time"
 
     _ "modernc.org/sqlite"
 )
 
-var DB *sql.DB
+// SQLStore — TaskStore поверх SQL-базы: SQLite или PostgreSQL.
+type SQLStore struct {
+    db      *sql.DB
+    dialect *dialect
+    // owner — пользователь, чьи данные видит хранилище; 0 — общий аккаунт.
+    owner int
 
-const schema = `
-CREATE TABLE scheduler (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    date CHAR(8) NOT NULL DEFAULT "",
-    title VARCHAR(255) NOT NULL DEFAULT "",
-    comment TEXT NOT NULL DEFAULT "",
-    repeat VARCHAR(128) NOT NULL DEFAULT ""
-);
+    // UndoWindow — сколько времени удалённую или выполненную задачу можно вернуть.
+    UndoWindow time.Duration
+}
+
+var _ TaskStore = (*SQLStore)(nil)
 
-CREATE INDEX idx_date ON scheduler(date);
-`
+// OpenSQLite подключается к файлу SQLite без применения миграций.
+func OpenSQLite(dbFile string) (*SQLStore, error) {
+    return open(sqliteDialect, dbFile)
+}
+
+// NewSQLiteStore подключается к файлу SQLite и обновляет его схему до последней версии.
+func NewSQLiteStore(dbFile string) (*SQLStore, error) {
+    return newStore(sqliteDialect, dbFile)
+}
 
-func Init(dbFile string) error {
-    _, err := os.Stat(dbFile)
-    install := false
+func open(d *dialect, dsn string) (*SQLStore, error) {
+    db, err := sql.Open(d.driver, dsn)
     if err != nil {
-        install = true
+        return nil, err
     }
+    return &SQLStore{db: db, dialect: d, UndoWindow: defaultUndoWindow}, nil
+}
 
-    var errOpen error
-    DB, errOpen = sql.Open("sqlite", dbFile)
-    if errOpen != nil {
-        return errOpen
+func newStore(d *dialect, dsn string) (*SQLStore, error) {
+    s, err := open(d, dsn)
+    if err != nil {
+        return nil, err
     }
 
-    if install {
-        _, err = DB.Exec(schema)
-        if err != nil {
-            return err
-        }
-        log.Println("База данных создана и таблица scheduler добавлена")
+    from, to, err := s.Migrate()
+    if err != nil {
+        s.Close()
+        return nil, err
     }
+    if from != to {
+        log.Printf("Схема базы данных обновлена с версии %d до %d", from, to)
+    }
+
+    return s, nil
+}
+
+// ForUser возвращает хранилище, которое видит и меняет только данные
+// пользователя userID. Соединение с базой общее с s.
+func (s *SQLStore) ForUser(userID int) TaskStore {
+    scoped := *s
+    scoped.owner = userID
+    return &scoped
+}
+
+// conn возвращает соединение, которое принимает запросы с параметрами «?».
+func (s *SQLStore) conn() dbConn {
+    return dbConn{q: s.db, d: s.dialect}
+}
+
+func (s *SQLStore) begin() (*sqlTx, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return nil, err
+    }
+    return &sqlTx{dbConn: dbConn{q: tx, d: s.dialect}, tx: tx}, nil
+}
 
-    return nil
+func (s *SQLStore) Close() error {
+    return s.db.Close()
 }
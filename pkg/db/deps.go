@@ -0,0 +1,141 @@
+// pkg/db/deps.go
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// Dependency — связь «задача TaskID ждёт задачу DependsOn».
+type Dependency struct {
+	TaskID    int    `json:"task_id,string"`
+	DependsOn int    `json:"depends_on,string"`
+	Title     string `json:"title"`
+	// Open — предварительная задача ещё не выполнена.
+	Open bool `json:"open"`
+}
+
+var (
+	// ErrDependencyCycle — связь замкнула бы цепочку зависимостей в цикл.
+	ErrDependencyCycle = errors.New("зависимость создаёт цикл")
+	// ErrDependencyHidden — владелец зависимой задачи не видит предварительную
+	// и не смог бы ни узнать, чего ждёт его задача, ни выполнить её.
+	ErrDependencyHidden = errors.New("предварительная задача недоступна владельцу задачи")
+)
+
+// Связи хранятся и после удаления задач: удалённую задачу можно вернуть
+// из корзины, и её зависимости должны вернуться вместе с ней. Связь с
+// удалённой задачей ничего не блокирует.
+//
+// Предварительная задача открыта, пока она есть в scheduler и не выполнялась
+// с начала текущего цикла зависимой задачи: однократная задача при
+// выполнении удаляется, у повторяющейся остаётся запись в completions.
+// Цикл начинается с появления связи и заново — с каждым выполнением
+// зависимой задачи, поэтому повторяющаяся предварительная задача снова
+// блокирует следующее повторение. Порядок выполнений берётся по id записей
+// completions: done_at хранится с точностью до секунды.
+const openDependency = "EXISTS (SELECT 1 FROM scheduler p WHERE p.id = task_deps.depends_on)" +
+	" AND NOT EXISTS (SELECT 1 FROM completions c WHERE c.task_id = task_deps.depends_on" +
+	" AND c.done_at >= task_deps.created_at" +
+	" AND NOT EXISTS (SELECT 1 FROM completions d WHERE d.task_id = task_deps.task_id AND d.id > c.id))"
+
+// blockedExpr — истинно, если у задачи scheduler.id есть открытые
+// предварительные задачи.
+const blockedExpr = "EXISTS (SELECT 1 FROM task_deps WHERE task_deps.task_id = scheduler.id AND " +
+	openDependency + ")"
+
+// AddDependency связывает задачи: taskID нельзя выполнить раньше dependsOn.
+func (s *SQLStore) AddDependency(taskID, dependsOn int) error {
+	if taskID == dependsOn {
+		return ErrDependencyCycle
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	task, err := s.editTask(tx, taskID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.getTask(tx, dependsOn); err != nil {
+		return err
+	}
+	// Редактор чужой задачи выбирает только из задач, которые видит и её
+	// владелец.
+	owner := *s
+	owner.owner = task.OwnerID
+	if _, err := owner.getTask(tx, dependsOn); err != nil {
+		return ErrDependencyHidden
+	}
+
+	// Цикл появится, если dependsOn уже прямо или через другие задачи ждёт taskID.
+	var cycle int
+	err = tx.QueryRow(`
+		WITH RECURSIVE chain(id) AS (
+			SELECT depends_on FROM task_deps WHERE task_id = ?
+			UNION
+			SELECT task_deps.depends_on FROM task_deps JOIN chain ON task_deps.task_id = chain.id
+		)
+		SELECT COUNT(*) FROM chain WHERE id = ?`,
+		dependsOn, taskID,
+	).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle > 0 {
+		return ErrDependencyCycle
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO task_deps (task_id, depends_on, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		taskID, dependsOn, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) RemoveDependency(taskID, dependsOn int) error {
+	if _, err := s.editTask(s.conn(), taskID); err != nil {
+		return err
+	}
+	res, err := s.conn().Exec("DELETE FROM task_deps WHERE task_id = ? AND depends_on = ?", taskID, dependsOn)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errors.New("dependency not found")
+	}
+	return nil
+}
+
+// Dependencies возвращает существующие предварительные задачи taskID.
+func (s *SQLStore) Dependencies(taskID int) ([]Dependency, error) {
+	if _, err := s.getTask(s.conn(), taskID); err != nil {
+		return nil, err
+	}
+	rows, err := s.conn().Query(
+		"SELECT task_deps.task_id, task_deps.depends_on, scheduler.title, "+openDependency+
+			" FROM task_deps JOIN scheduler ON scheduler.id = task_deps.depends_on"+
+			" WHERE task_deps.task_id = ? ORDER BY scheduler.date, scheduler.id",
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deps := []Dependency{}
+	for rows.Next() {
+		var d Dependency
+		if err := rows.Scan(&d.TaskID, &d.DependsOn, &d.Title, &d.Open); err != nil {
+			return nil, err
+		}
+		deps = append(deps, d)
+	}
+	return deps, rows.Err()
+}
@@ -0,0 +1,104 @@
+// pkg/db/dialect.go
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// dialect — различия между поддерживаемыми СУБД. Запросы в пакете пишутся
+// с параметрами «?», а dbConn при необходимости переписывает их под СУБД.
+type dialect struct {
+	name       string
+	driver     string
+	numbered   bool // параметры вида $1, $2, …
+	like       string
+	fts        bool // есть полнотекстовый индекс scheduler_fts
+	rowLocks   bool // есть SELECT … FOR UPDATE
+	migrations []migration
+	// schemaVersionTable создаёт таблицу учёта применённых миграций.
+	schemaVersionTable string
+}
+
+var sqliteDialect = &dialect{
+	name:       "sqlite",
+	driver:     "sqlite",
+	like:       "LIKE",
+	fts:        true,
+	migrations: sqliteMigrations,
+	schemaVersionTable: `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT "",
+    applied_at CHAR(20) NOT NULL DEFAULT ""
+);
+`,
+}
+
+var postgresDialect = &dialect{
+	name:       "postgres",
+	driver:     "pgx",
+	numbered:   true,
+	like:       "ILIKE",
+	rowLocks:   true,
+	migrations: postgresMigrations,
+	schemaVersionTable: `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    applied_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+`,
+}
+
+// rebind заменяет параметры «?» на нумерованные, если СУБД этого требует.
+func (d *dialect) rebind(query string) string {
+	if !d.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// dbConn — querier, который приводит запросы к синтаксису СУБД.
+type dbConn struct {
+	q querier
+	d *dialect
+}
+
+func (c dbConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.q.Exec(c.d.rebind(query), args...)
+}
+
+func (c dbConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.q.Query(c.d.rebind(query), args...)
+}
+
+func (c dbConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.q.QueryRow(c.d.rebind(query), args...)
+}
+
+// sqlTx — транзакция с тем же переписыванием запросов, что и у dbConn.
+type sqlTx struct {
+	dbConn
+	tx *sql.Tx
+}
+
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
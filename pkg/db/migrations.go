@@ -0,0 +1,383 @@
+// pkg/db/migrations.go
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// migration — шаг изменения схемы. Шаги применяются по возрастанию version,
+// каждый в своей транзакции вместе с записью в schema_version. У каждой СУБД
+// свой список шагов, но номера версий в них совпадают.
+type migration struct {
+	version int
+	name    string
+	up      func(q querier) error
+}
+
+var sqliteMigrations = []migration{
+	{1, "scheduler", execSQL(`
+CREATE TABLE IF NOT EXISTS scheduler (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date CHAR(8) NOT NULL DEFAULT "",
+    title VARCHAR(255) NOT NULL DEFAULT "",
+    comment TEXT NOT NULL DEFAULT "",
+    repeat VARCHAR(128) NOT NULL DEFAULT ""
+);
+
+CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
+`)},
+	{2, "repeat end conditions", addColumns("scheduler",
+		`repeat_count INTEGER NOT NULL DEFAULT 0`,
+		`repeat_until CHAR(8) NOT NULL DEFAULT ""`,
+	)},
+	{3, "time and duration", addColumns("scheduler",
+		`time CHAR(5) NOT NULL DEFAULT ""`,
+		`duration INTEGER NOT NULL DEFAULT 0`,
+	)},
+	{4, "completions", execSQL(`
+CREATE TABLE IF NOT EXISTS completions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT "",
+    date CHAR(8) NOT NULL DEFAULT "",
+    done_at CHAR(20) NOT NULL DEFAULT ""
+);
+
+CREATE INDEX IF NOT EXISTS idx_completions_task ON completions(task_id, done_at);
+CREATE INDEX IF NOT EXISTS idx_completions_done_at ON completions(done_at);
+`)},
+	{5, "trash", execSQL(`
+CREATE TABLE IF NOT EXISTS trash (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    action VARCHAR(16) NOT NULL DEFAULT "",
+    task TEXT NOT NULL DEFAULT "",
+    completion_id INTEGER NOT NULL DEFAULT 0,
+    created_at CHAR(20) NOT NULL DEFAULT ""
+);
+
+CREATE INDEX IF NOT EXISTS idx_trash_task ON trash(task_id, id);
+CREATE INDEX IF NOT EXISTS idx_trash_created_at ON trash(created_at);
+`)},
+	{6, "full-text search", execSQL(`
+CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(
+    title, comment,
+    content='scheduler', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_insert AFTER INSERT ON scheduler BEGIN
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_delete AFTER DELETE ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+END;
+
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+
+INSERT INTO scheduler_fts(scheduler_fts) VALUES ('rebuild');
+`)},
+	{7, "creation time and sort indexes", sequence(
+		addColumns("scheduler", `created_at CHAR(20) NOT NULL DEFAULT ""`),
+		execSQL(sortIndexes),
+	)},
+	{8, "tags", execSQL(`
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS task_tags (
+    task_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_tags_tag ON task_tags(tag_id, task_id);
+
+-- Внешние ключи в SQLite по умолчанию не проверяются, поэтому связи удаляемой
+-- задачи убирает триггер.
+CREATE TRIGGER IF NOT EXISTS scheduler_tags_delete AFTER DELETE ON scheduler BEGIN
+    DELETE FROM task_tags WHERE task_id = old.id;
+END;
+`)},
+	{9, "priority", addColumns("scheduler",
+		`priority INTEGER NOT NULL DEFAULT 0`,
+	)},
+	{10, "projects", sequence(
+		execSQL(`
+CREATE TABLE IF NOT EXISTS projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL DEFAULT "",
+    color VARCHAR(7) NOT NULL DEFAULT "",
+    archived INTEGER NOT NULL DEFAULT 0,
+    created_at CHAR(20) NOT NULL DEFAULT ""
+);
+`),
+		addColumns("scheduler", `project_id INTEGER NOT NULL DEFAULT 0`),
+		execSQL(projectIndex),
+	)},
+	{11, "checklist", execSQL(`
+CREATE TABLE IF NOT EXISTS checklist (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT "",
+    position INTEGER NOT NULL DEFAULT 0,
+    done INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_checklist_task ON checklist(task_id, position);
+
+CREATE TRIGGER IF NOT EXISTS scheduler_checklist_delete AFTER DELETE ON scheduler BEGIN
+    DELETE FROM checklist WHERE task_id = old.id;
+END;
+`)},
+	{12, "dependencies", execSQL(taskDepsTable)},
+	{13, "users", sequence(
+		execSQL(`
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    login VARCHAR(64) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL DEFAULT "",
+    created_at CHAR(20) NOT NULL DEFAULT ""
+);
+`),
+		addColumns("scheduler", `owner_id INTEGER NOT NULL DEFAULT 0`),
+		addColumns("projects", `owner_id INTEGER NOT NULL DEFAULT 0`),
+		addColumns("completions", `owner_id INTEGER NOT NULL DEFAULT 0`),
+		addColumns("trash", `owner_id INTEGER NOT NULL DEFAULT 0`),
+		// Имена меток теперь уникальны только у одного владельца. Ограничение
+		// UNIQUE в SQLite не снять без пересоздания таблицы.
+		execSQL(`
+CREATE TABLE tags_owned (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    name VARCHAR(64) NOT NULL,
+    UNIQUE (owner_id, name)
+);
+
+INSERT INTO tags_owned (id, name) SELECT id, name FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_owned RENAME TO tags;
+`),
+		execSQL(ownerIndexes),
+	)},
+	{14, "shares", execSQL(`
+CREATE TABLE IF NOT EXISTS shares (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    user_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL DEFAULT 0,
+    project_id INTEGER NOT NULL DEFAULT 0,
+    role VARCHAR(16) NOT NULL DEFAULT "",
+    created_at CHAR(20) NOT NULL DEFAULT "",
+    UNIQUE (user_id, task_id, project_id)
+);
+` + sharesIndexes)},
+	{15, "sessions", execSQL(sessionsTables)},
+	{16, "api keys", execSQL(`
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    name VARCHAR(255) NOT NULL DEFAULT "",
+    prefix VARCHAR(16) NOT NULL DEFAULT "",
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    read_only INTEGER NOT NULL DEFAULT 0,
+    created_at CHAR(20) NOT NULL DEFAULT "",
+    last_used_at CHAR(20) NOT NULL DEFAULT ""
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+`)},
+	{17, "session password", addColumns("sessions", `password_hash VARCHAR(64) NOT NULL DEFAULT ''`)},
+}
+
+// sessionsTables одинаковы для SQLite и PostgreSQL.
+const sessionsTables = `
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    refresh_hash VARCHAR(64) NOT NULL UNIQUE,
+    previous_hash VARCHAR(64) NOT NULL DEFAULT '',
+    access_jti VARCHAR(64) NOT NULL DEFAULT '',
+    access_expires_at VARCHAR(20) NOT NULL DEFAULT '',
+    created_at VARCHAR(20) NOT NULL DEFAULT '',
+    refreshed_at VARCHAR(20) NOT NULL DEFAULT '',
+    expires_at VARCHAR(20) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id, expires_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous ON sessions(previous_hash);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at VARCHAR(20) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires ON revoked_tokens(expires_at);
+`
+
+// sharesIndexes ускоряют проверку доступа к задаче. Записи об удалённых
+// задачах остаются, чтобы доступ вернулся вместе с задачей из корзины.
+const sharesIndexes = `
+CREATE INDEX IF NOT EXISTS idx_shares_task ON shares(task_id);
+CREATE INDEX IF NOT EXISTS idx_shares_project ON shares(project_id);
+CREATE INDEX IF NOT EXISTS idx_shares_owner ON shares(owner_id, task_id, project_id);
+`
+
+// ownerIndexes ускоряют выборку данных одного пользователя.
+const ownerIndexes = `
+CREATE INDEX IF NOT EXISTS idx_scheduler_owner ON scheduler(owner_id, date, time, id);
+CREATE INDEX IF NOT EXISTS idx_projects_owner ON projects(owner_id);
+CREATE INDEX IF NOT EXISTS idx_completions_owner ON completions(owner_id, done_at);
+CREATE INDEX IF NOT EXISTS idx_trash_owner ON trash(owner_id, id);
+`
+
+// taskDepsTable одинакова для SQLite и PostgreSQL. Внешних ключей нет:
+// связи удалённых задач остаются до их восстановления из корзины.
+const taskDepsTable = `
+CREATE TABLE IF NOT EXISTS task_deps (
+    task_id INTEGER NOT NULL,
+    depends_on INTEGER NOT NULL,
+    created_at VARCHAR(20) NOT NULL DEFAULT '',
+    PRIMARY KEY (task_id, depends_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_deps_depends_on ON task_deps(depends_on);
+`
+
+const projectIndex = `
+CREATE INDEX IF NOT EXISTS idx_scheduler_project ON scheduler(project_id);
+`
+
+// sortIndexes покрывают ключи сортировки списка задач из sortKeys.
+const sortIndexes = `
+CREATE INDEX IF NOT EXISTS idx_scheduler_date_time ON scheduler(date, time, id);
+CREATE INDEX IF NOT EXISTS idx_scheduler_title ON scheduler(title, id);
+CREATE INDEX IF NOT EXISTS idx_scheduler_created ON scheduler(created_at, id);
+`
+
+// skip — шаг, которому нечего делать в этой СУБД; нужен, чтобы номера версий
+// совпадали во всех списках миграций.
+func skip(q querier) error {
+	return nil
+}
+
+// sequence выполняет шаги миграции по порядку.
+func sequence(steps ...func(q querier) error) func(q querier) error {
+	return func(q querier) error {
+		for _, step := range steps {
+			if err := step(q); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func execSQL(query string) func(q querier) error {
+	return func(q querier) error {
+		_, err := q.Exec(query)
+		return err
+	}
+}
+
+// addColumns добавляет в таблицу недостающие столбцы. Базы, созданные до
+// появления миграций, могли уже получить часть столбцов из старой схемы.
+func addColumns(table string, columns ...string) func(q querier) error {
+	return func(q querier) error {
+		existing, err := tableColumns(q, table)
+		if err != nil {
+			return err
+		}
+		for _, def := range columns {
+			name := strings.Fields(def)[0]
+			if existing[name] {
+				continue
+			}
+			if _, err := q.Exec("ALTER TABLE " + table + " ADD COLUMN " + def); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func tableColumns(q querier, table string) (map[string]bool, error) {
+	rows, err := q.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// SchemaVersion возвращает номер последней применённой миграции.
+func (s *SQLStore) SchemaVersion() (int, error) {
+	if _, err := s.conn().Exec(s.dialect.schemaVersionTable); err != nil {
+		return 0, err
+	}
+	var version int
+	err := s.conn().QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// Migrate применяет все ещё не применённые миграции и возвращает версию
+// схемы до и после обновления.
+func (s *SQLStore) Migrate() (from, to int, err error) {
+	from, err = s.SchemaVersion()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to = from
+	for _, m := range s.dialect.migrations {
+		if m.version <= to {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return from, to, fmt.Errorf("миграция %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("Применена миграция %d: %s", m.version, m.name)
+		to = m.version
+	}
+	return from, to, nil
+}
+
+func (s *SQLStore) applyMigration(m migration) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,138 @@
+// pkg/db/page.go
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Порядок сортировки списка задач.
+const (
+	SortDate    = "date"
+	SortTitle   = "title"
+	SortID      = "id"
+	SortCreated = "created"
+	// SortPriority — сначала важные, среди равных — по дате.
+	SortPriority = "priority"
+	// SortDatePriority — по дате, в пределах дня сначала важные.
+	SortDatePriority = "date_priority"
+	// SortRank — по релевантности, только при полнотекстовом поиске.
+	SortRank = "rank"
+)
+
+// sortKeys — выражения ключа сортировки. Последним всегда идёт id, чтобы
+// ключ был уникальным и страницы не теряли и не повторяли задачи. Важность
+// входит в ключ со знаком минус: все выражения сортируются в одну сторону.
+var sortKeys = map[string][]string{
+	SortDate:         {"date", "time", "id"},
+	SortTitle:        {"title", "id"},
+	SortID:           {"id"},
+	SortCreated:      {"created_at", "id"},
+	SortPriority:     {"-priority", "date", "time", "id"},
+	SortDatePriority: {"date", "-priority", "time", "id"},
+	SortRank:         {"fts_rank", "id"},
+}
+
+// ErrInvalidPage — неверные параметры страницы: сортировка или курсор.
+var ErrInvalidPage = errors.New("неверные параметры страницы")
+
+// Page — какую страницу списка задач вернуть.
+type Page struct {
+	// Limit — размер страницы; 0 — без ограничения.
+	Limit int
+	// Sort — один из Sort*; по умолчанию SortRank при поиске по тексту, иначе SortDate.
+	Sort string
+	Desc bool
+	// Cursor — значение next с предыдущей страницы; "" — первая страница.
+	Cursor string
+}
+
+// cursor — ключ сортировки последней задачи страницы. Следующая страница
+// начинается строго после него, поэтому выборка не пропускает строки через OFFSET.
+type cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	// Keys — значения выражений sortKeys[Sort] в том же порядке.
+	Keys []interface{} `json:"k"`
+}
+
+func newCursor(sort string, desc bool, t Task, rank float64) cursor {
+	c := cursor{Sort: sort, Desc: desc}
+	switch sort {
+	case SortDate:
+		c.Keys = []interface{}{t.Date, t.Time, t.ID}
+	case SortTitle:
+		c.Keys = []interface{}{t.Title, t.ID}
+	case SortID:
+		c.Keys = []interface{}{t.ID}
+	case SortCreated:
+		c.Keys = []interface{}{t.CreatedAt, t.ID}
+	case SortPriority:
+		c.Keys = []interface{}{-t.Priority, t.Date, t.Time, t.ID}
+	case SortDatePriority:
+		c.Keys = []interface{}{t.Date, -t.Priority, t.Time, t.ID}
+	case SortRank:
+		c.Keys = []interface{}{rank, t.ID}
+	}
+	return c
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("%w: курсор повреждён", ErrInvalidPage)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("%w: курсор повреждён", ErrInvalidPage)
+	}
+	// Числа возвращаются в запрос с исходным типом: целые — как целые.
+	for i, k := range c.Keys {
+		switch v := k.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				c.Keys[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				c.Keys[i] = f
+			} else {
+				return c, fmt.Errorf("%w: курсор повреждён", ErrInvalidPage)
+			}
+		case string:
+		default:
+			return c, fmt.Errorf("%w: курсор повреждён", ErrInvalidPage)
+		}
+	}
+	return c, nil
+}
+
+// keyset возвращает условие «строка после курсора» и порядок сортировки.
+func keyset(sort string, desc bool, c *cursor) (after string, args []interface{}, orderBy string) {
+	keys := sortKeys[sort]
+	dir, cmp := " ASC", ">"
+	if desc {
+		dir, cmp = " DESC", "<"
+	}
+	order := make([]string, len(keys))
+	for i, k := range keys {
+		order[i] = k + dir
+	}
+	orderBy = " ORDER BY " + strings.Join(order, ", ")
+
+	if c != nil {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+		after = "(" + strings.Join(keys, ", ") + ") " + cmp + " (" + placeholders + ")"
+		args = c.Keys
+	}
+	return after, args, orderBy
+}
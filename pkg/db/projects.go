@@ -0,0 +1,173 @@
+// pkg/db/projects.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Project — проект, в который собираются задачи. Задачи без проекта
+// имеют ProjectID = 0.
+type Project struct {
+	ID       int    `json:"id,string"`
+	Name     string `json:"name"`
+	Color    string `json:"color,omitempty"`
+	Archived bool   `json:"archived"`
+	// Tasks — число задач в проекте.
+	Tasks int `json:"tasks,string"`
+}
+
+// ProjectTasks — что сделать с задачами удаляемого проекта: удалить их или
+// перенести в проект MoveTo (0 — оставить без проекта).
+type ProjectTasks struct {
+	Delete bool
+	MoveTo int
+}
+
+var errProjectNotFound = errors.New("project not found")
+
+const projectQuery = "SELECT projects.id, projects.name, projects.color, projects.archived, COUNT(scheduler.id)" +
+	" FROM projects LEFT JOIN scheduler ON scheduler.project_id = projects.id"
+
+const projectGroupBy = " GROUP BY projects.id, projects.name, projects.color, projects.archived"
+
+func scanProject(row rowScanner) (Project, error) {
+	var p Project
+	err := row.Scan(&p.ID, &p.Name, &p.Color, &p.Archived, &p.Tasks)
+	return p, err
+}
+
+// Projects возвращает проекты по имени; архивные — только если includeArchived.
+func (s *SQLStore) Projects(includeArchived bool) ([]Project, error) {
+	query := projectQuery + " WHERE projects.owner_id = ?"
+	args := []interface{}{s.owner}
+	if !includeArchived {
+		query += " AND projects.archived = ?"
+		args = append(args, false)
+	}
+	rows, err := s.conn().Query(query+projectGroupBy+" ORDER BY projects.name, projects.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func (s *SQLStore) GetProject(id int) (Project, error) {
+	return s.getProject(s.conn(), id)
+}
+
+func (s *SQLStore) getProject(q querier, id int) (Project, error) {
+	p, err := scanProject(q.QueryRow(
+		projectQuery+" WHERE projects.id = ? AND projects.owner_id = ?"+projectGroupBy, id, s.owner,
+	))
+	if err == sql.ErrNoRows {
+		return p, errProjectNotFound
+	}
+	return p, err
+}
+
+func (s *SQLStore) AddProject(p Project) (int, error) {
+	var id int
+	err := s.conn().QueryRow(
+		"INSERT INTO projects (name, color, archived, created_at, owner_id) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		p.Name, p.Color, p.Archived, time.Now().UTC().Format(time.RFC3339), s.owner,
+	).Scan(&id)
+	return id, err
+}
+
+func (s *SQLStore) UpdateProject(p Project) error {
+	res, err := s.conn().Exec(
+		"UPDATE projects SET name = ?, color = ?, archived = ? WHERE id = ? AND owner_id = ?",
+		p.Name, p.Color, p.Archived, p.ID, s.owner,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errProjectNotFound
+	}
+	return nil
+}
+
+// DeleteProject удаляет проект, перенося или удаляя его задачи. Удалённые
+// задачи попадают в корзину, как при DeleteTask.
+func (s *SQLStore) DeleteProject(id int, tasks ProjectTasks) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := s.getProject(tx, id); err != nil {
+		return err
+	}
+
+	if tasks.Delete {
+		ids, err := projectTaskIDs(tx, id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, taskID := range ids {
+			task, err := s.getTask(tx, taskID)
+			if err != nil {
+				return err
+			}
+			if err := s.addTrash(tx, task, trashDelete, 0, now); err != nil {
+				return err
+			}
+			if err := deleteTask(tx, taskID); err != nil {
+				return err
+			}
+		}
+	} else {
+		if tasks.MoveTo == id {
+			return errors.New("нельзя перенести задачи в удаляемый проект")
+		}
+		if tasks.MoveTo != 0 {
+			if _, err := s.getProject(tx, tasks.MoveTo); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("UPDATE scheduler SET project_id = ? WHERE project_id = ?", tasks.MoveTo, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM shares WHERE project_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM projects WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func projectTaskIDs(q querier, projectID int) ([]int, error) {
+	rows, err := q.Query("SELECT id FROM scheduler WHERE project_id = ?", projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,119 @@
+// pkg/db/search.go
+package db
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Границы совпадения в сниппете. Управляющие символы не встречаются в тексте
+// задач, поэтому после экранирования их можно безопасно заменить на <mark>.
+const (
+	snippetStart = "\x02"
+	snippetEnd   = "\x03"
+)
+
+// snippetTokens — сколько слов вокруг совпадения попадает в сниппет.
+const snippetTokens = 12
+
+// TaskFilter — условия отбора задач. Все заданные условия должны выполниться.
+type TaskFilter struct {
+	Terms []SearchTerm
+	// Date, From, To — точная дата и границы диапазона дат включительно
+	// в формате utils.DateLayout.
+	Date string
+	From string
+	To   string
+	// Repeat отбирает только повторяющиеся (true) или однократные (false) задачи.
+	Repeat *bool
+	// Shared отбирает только открытые пользователю чужие задачи (true) или
+	// только свои (false).
+	Shared *bool
+	// Blocked отбирает только заблокированные (true) или только доступные
+	// (false) задачи.
+	Blocked *bool
+	// Project — только задачи проекта; 0 — задачи без проекта.
+	Project *int
+	// Tags — метки задачи: нужны все или, если AnyTag, хотя бы одна.
+	Tags   []string
+	AnyTag bool
+}
+
+// SearchTerm — условие на текст задачи.
+type SearchTerm struct {
+	// Field — "title", "comment" или "" для обоих полей.
+	Field string
+	Text  string
+	// Phrase требует совпадения фразы целиком, иначе слова ищутся по началу.
+	Phrase bool
+	Negate bool
+}
+
+// fields возвращает столбцы условия. Имена столбцов подставляются в SQL,
+// поэтому допустимы только известные поля.
+func (t SearchTerm) fields() []string {
+	switch t.Field {
+	case "title", "comment":
+		return []string{t.Field}
+	}
+	return []string{"title", "comment"}
+}
+
+// ftsTerm переводит условие в запрос FTS5. Пустой результат означает, что
+// в тексте нет ни одного слова и условие проверяется через LIKE.
+func ftsTerm(t SearchTerm) string {
+	words := ftsWords(t.Text)
+	if len(words) == 0 {
+		return ""
+	}
+	column := ""
+	if fields := t.fields(); len(fields) == 1 {
+		column = fields[0] + " : "
+	}
+	if t.Phrase {
+		return column + ftsString(strings.Join(words, " "))
+	}
+	parts := make([]string, len(words))
+	for i, word := range words {
+		parts[i] = column + ftsString(word) + "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+// ftsMatch объединяет положительные текстовые условия в один запрос FTS5.
+func ftsMatch(terms []SearchTerm) string {
+	var parts []string
+	for _, t := range terms {
+		if match := ftsTerm(t); match != "" && !t.Negate {
+			parts = append(parts, match)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ftsWords делит текст на слова так же, как токенизатор unicode61.
+func ftsWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func ftsString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// ftsJoin соединяет scheduler с результатами полнотекстового поиска: к строкам
+// добавляются столбцы fts_rank (меньше — релевантнее) и fts_snippet.
+func ftsJoin(match string) (join string, args []interface{}) {
+	join = " JOIN (SELECT rowid AS fts_id, rank AS fts_rank," +
+		" snippet(scheduler_fts, -1, ?, ?, '…', ?) AS fts_snippet" +
+		" FROM scheduler_fts WHERE scheduler_fts MATCH ?) ON fts_id = id"
+	return join, []interface{}{snippetStart, snippetEnd, snippetTokens, match}
+}
+
+// highlight экранирует сниппет для HTML и выделяет совпадения тегом <mark>.
+func highlight(snippet string) string {
+	return strings.NewReplacer(snippetStart, "<mark>", snippetEnd, "</mark>").
+		Replace(html.EscapeString(snippet))
+}
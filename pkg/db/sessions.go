@@ -0,0 +1,208 @@
+// pkg/db/sessions.go
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Session — вход пользователя с одного устройства. Сессию продлевают
+// refresh-токеном: при каждом обновлении выдаётся новый refresh-токен,
+// а прежний перестаёт действовать.
+type Session struct {
+	ID     string `json:"id"`
+	UserID int    `json:"-"`
+	// AccessJTI и AccessExpiresAt — последний выданный в сессии access-токен;
+	// при закрытии сессии он попадает в список отозванных.
+	AccessJTI       string `json:"-"`
+	AccessExpiresAt string `json:"-"`
+	// PasswordHash — отпечаток пароля, с которым открыта сессия. После смены
+	// пароля сессию нельзя продлить.
+	PasswordHash string `json:"-"`
+	CreatedAt    string `json:"created_at"`
+	RefreshedAt  string `json:"refreshed_at"`
+	ExpiresAt    string `json:"expires_at"`
+	UserAgent    string `json:"user_agent,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	// Current — сессия, из которой пришёл запрос.
+	Current bool `json:"current,omitempty"`
+}
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrRefreshReused — предъявлен уже заменённый refresh-токен. Скорее
+	// всего, токен украден, поэтому сессия закрывается.
+	ErrRefreshReused = errors.New("refresh-токен уже использован, сессия закрыта")
+)
+
+const sessionColumns = "id, user_id, access_jti, access_expires_at, password_hash, created_at, refreshed_at, expires_at," +
+	" user_agent, ip"
+
+func scanSession(row rowScanner) (Session, error) {
+	var s Session
+	err := row.Scan(&s.ID, &s.UserID, &s.AccessJTI, &s.AccessExpiresAt, &s.PasswordHash,
+		&s.CreatedAt, &s.RefreshedAt, &s.ExpiresAt, &s.UserAgent, &s.IP)
+	return s, err
+}
+
+// tokenHash — в базе хранятся только хеши refresh-токенов.
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// AddSession сохраняет новую сессию с refresh-токеном refreshToken.
+func (s *SQLStore) AddSession(sess Session, refreshToken string) error {
+	now := formatTime(time.Now())
+	_, err := s.conn().Exec(
+		"INSERT INTO sessions (id, user_id, refresh_hash, access_jti, access_expires_at, password_hash, created_at,"+
+			" refreshed_at, expires_at, user_agent, ip) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		sess.ID, sess.UserID, tokenHash(refreshToken), sess.AccessJTI, sess.AccessExpiresAt, sess.PasswordHash,
+		now, now, sess.ExpiresAt, sess.UserAgent, sess.IP,
+	)
+	return err
+}
+
+// RotateSession заменяет refresh-токен сессии на newRefreshToken и
+// запоминает новый access-токен из next. Прежний access-токен отзывается.
+func (s *SQLStore) RotateSession(refreshToken, newRefreshToken string, next Session) (Session, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return Session{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	hash := tokenHash(refreshToken)
+	sess, err := scanSession(tx.QueryRow(
+		"SELECT "+sessionColumns+" FROM sessions WHERE refresh_hash = ? AND expires_at > ?", hash, formatTime(now),
+	))
+	if err == sql.ErrNoRows {
+		// Заменённый токен ищется отдельно: его повторное предъявление
+		// закрывает сессию.
+		reused, err := scanSession(tx.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE previous_hash = ?", hash))
+		if err == sql.ErrNoRows {
+			return Session{}, ErrSessionNotFound
+		}
+		if err != nil {
+			return Session{}, err
+		}
+		if err := revokeSession(tx, reused); err != nil {
+			return Session{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Session{}, err
+		}
+		return Session{}, ErrRefreshReused
+	}
+	if err != nil {
+		return Session{}, err
+	}
+
+	if err := revokeToken(tx, sess.AccessJTI, sess.AccessExpiresAt); err != nil {
+		return Session{}, err
+	}
+	sess.AccessJTI = next.AccessJTI
+	sess.AccessExpiresAt = next.AccessExpiresAt
+	sess.RefreshedAt = formatTime(now)
+	sess.ExpiresAt = next.ExpiresAt
+	_, err = tx.Exec(
+		"UPDATE sessions SET refresh_hash = ?, previous_hash = ?, access_jti = ?, access_expires_at = ?,"+
+			" refreshed_at = ?, expires_at = ? WHERE id = ?",
+		tokenHash(newRefreshToken), hash, sess.AccessJTI, sess.AccessExpiresAt, sess.RefreshedAt, sess.ExpiresAt, sess.ID,
+	)
+	if err != nil {
+		return Session{}, err
+	}
+	return sess, tx.Commit()
+}
+
+// Sessions возвращает действующие сессии пользователя, начиная с последних.
+func (s *SQLStore) Sessions() ([]Session, error) {
+	rows, err := s.conn().Query(
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND expires_at > ? ORDER BY refreshed_at DESC, id",
+		s.owner, formatTime(time.Now()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession закрывает сессию пользователя и отзывает её access-токен.
+func (s *SQLStore) RevokeSession(id string) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sess, err := scanSession(tx.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ? AND user_id = ?", id, s.owner))
+	if err == sql.ErrNoRows {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := revokeSession(tx, sess); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func revokeSession(q querier, sess Session) error {
+	if err := revokeToken(q, sess.AccessJTI, sess.AccessExpiresAt); err != nil {
+		return err
+	}
+	_, err := q.Exec("DELETE FROM sessions WHERE id = ?", sess.ID)
+	return err
+}
+
+// RevokeToken вносит access-токен jti в список отозванных до истечения его
+// срока.
+func (s *SQLStore) RevokeToken(jti string, expiresAt time.Time) error {
+	return revokeToken(s.conn(), jti, formatTime(expiresAt))
+}
+
+// revokeToken заодно чистит список от токенов, которые истекли сами, и от
+// закончившихся сессий.
+func revokeToken(q querier, jti, expiresAt string) error {
+	now := formatTime(time.Now())
+	if _, err := q.Exec("DELETE FROM revoked_tokens WHERE expires_at <= ?", now); err != nil {
+		return err
+	}
+	if _, err := q.Exec("DELETE FROM sessions WHERE expires_at <= ?", now); err != nil {
+		return err
+	}
+	if jti == "" || expiresAt <= now {
+		return nil
+	}
+	_, err := q.Exec(
+		"INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?) ON CONFLICT (jti) DO NOTHING", jti, expiresAt,
+	)
+	return err
+}
+
+// TokenRevoked сообщает, отозван ли access-токен jti.
+func (s *SQLStore) TokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := s.conn().QueryRow("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)", jti).Scan(&revoked)
+	return revoked, err
+}
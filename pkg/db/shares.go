@@ -0,0 +1,161 @@
+// pkg/db/shares.go
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// Роли пользователя, которому открыт доступ к чужой задаче или проекту.
+const (
+	// RoleViewer видит задачу, её чек-лист, зависимости и журнал.
+	RoleViewer = "viewer"
+	// RoleEditor вдобавок меняет и выполняет задачу. Удалять задачу и
+	// открывать к ней доступ может только владелец.
+	RoleEditor = "editor"
+)
+
+// ErrForbidden — у пользователя нет прав на действие с чужой задачей.
+var ErrForbidden = errors.New("недостаточно прав")
+
+// Share — доступ пользователя Login к задаче TaskID или ко всем задачам
+// проекта ProjectID.
+type Share struct {
+	ID        int    `json:"id,string"`
+	TaskID    int    `json:"task_id,string,omitempty"`
+	ProjectID int    `json:"project_id,string,omitempty"`
+	Login     string `json:"login"`
+	Role      string `json:"role"`
+}
+
+var errShareNotFound = errors.New("share not found")
+
+// shareMatch — условие «запись shares открывает задачу scheduler пользователю ?».
+const shareMatch = "shares.user_id = ? AND shares.owner_id = scheduler.owner_id" +
+	" AND (shares.task_id = scheduler.id OR (shares.project_id <> 0 AND shares.project_id = scheduler.project_id))"
+
+// accessClause — условие «пользователь видит задачу scheduler»: своя или
+// открыта ему.
+func accessClause(user int) (string, []interface{}) {
+	return "(scheduler.owner_id = ? OR EXISTS (SELECT 1 FROM shares WHERE " + shareMatch + "))",
+		[]interface{}{user, user}
+}
+
+// roleColumn — роль пользователя в задаче scheduler для Task.Shared: пусто
+// для своей задачи.
+func roleColumn(user int) (string, []interface{}) {
+	return "CASE WHEN scheduler.owner_id = ? THEN ''" +
+			" WHEN EXISTS (SELECT 1 FROM shares WHERE " + shareMatch + " AND shares.role = '" + RoleEditor + "')" +
+			" THEN '" + RoleEditor + "' ELSE '" + RoleViewer + "' END",
+		[]interface{}{user, user}
+}
+
+// editTask читает задачу, которую пользователь может менять: свою или
+// открытую ему с ролью RoleEditor.
+func (s *SQLStore) editTask(q querier, id int) (Task, error) {
+	t, err := s.getTask(q, id)
+	if err == nil && t.Shared == RoleViewer {
+		return t, ErrForbidden
+	}
+	return t, err
+}
+
+// ownTask читает задачу, только если она принадлежит пользователю.
+func (s *SQLStore) ownTask(q querier, id int) (Task, error) {
+	t, err := s.getTask(q, id)
+	if err == nil && t.Shared != "" {
+		return t, ErrForbidden
+	}
+	return t, err
+}
+
+// Shares возвращает, кому открыта задача taskID или проект projectID
+// пользователя.
+func (s *SQLStore) Shares(taskID, projectID int) ([]Share, error) {
+	if err := s.checkShareTarget(s.conn(), taskID, projectID); err != nil {
+		return nil, err
+	}
+	rows, err := s.conn().Query(
+		"SELECT shares.id, shares.task_id, shares.project_id, users.login, shares.role"+
+			" FROM shares JOIN users ON users.id = shares.user_id"+
+			" WHERE shares.owner_id = ? AND shares.task_id = ? AND shares.project_id = ? ORDER BY users.login",
+		s.owner, taskID, projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []Share{}
+	for rows.Next() {
+		var sh Share
+		if err := rows.Scan(&sh.ID, &sh.TaskID, &sh.ProjectID, &sh.Login, &sh.Role); err != nil {
+			return nil, err
+		}
+		shares = append(shares, sh)
+	}
+	return shares, rows.Err()
+}
+
+// AddShare открывает пользователю sh.Login доступ к задаче или проекту.
+// Повторный вызов для того же пользователя меняет роль.
+func (s *SQLStore) AddShare(sh Share) (int, error) {
+	if sh.Role != RoleViewer && sh.Role != RoleEditor {
+		return 0, errors.New("роль должна быть viewer или editor")
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := s.checkShareTarget(tx, sh.TaskID, sh.ProjectID); err != nil {
+		return 0, err
+	}
+	user, err := userByLogin(tx, sh.Login)
+	if err != nil {
+		return 0, err
+	}
+	if user.ID == s.owner {
+		return 0, errors.New("нельзя открыть доступ самому себе")
+	}
+
+	var id int
+	err = tx.QueryRow(
+		"INSERT INTO shares (owner_id, user_id, task_id, project_id, role, created_at) VALUES (?, ?, ?, ?, ?, ?)"+
+			" ON CONFLICT (user_id, task_id, project_id) DO UPDATE SET role = excluded.role RETURNING id",
+		s.owner, user.ID, sh.TaskID, sh.ProjectID, sh.Role, time.Now().UTC().Format(time.RFC3339),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// DeleteShare закрывает доступ. Удалить запись может и владелец, и тот,
+// кому доступ был открыт.
+func (s *SQLStore) DeleteShare(id int) error {
+	res, err := s.conn().Exec("DELETE FROM shares WHERE id = ? AND (owner_id = ? OR user_id = ?)", id, s.owner, s.owner)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errShareNotFound
+	}
+	return nil
+}
+
+// checkShareTarget проверяет, что указана ровно одна цель — задача или
+// проект — и что она принадлежит пользователю.
+func (s *SQLStore) checkShareTarget(q querier, taskID, projectID int) error {
+	if (taskID == 0) == (projectID == 0) {
+		return errors.New("укажите task_id или project_id")
+	}
+	if taskID != 0 {
+		_, err := s.ownTask(q, taskID)
+		return err
+	}
+	_, err := s.getProject(q, projectID)
+	return err
+}
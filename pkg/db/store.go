@@ -0,0 +1,81 @@
+// pkg/db/store.go
+package db
+
+import "time"
+
+// TaskStore — хранилище задач. Обработчики API работают только через этот
+// интерфейс, поэтому реализацию можно подменить.
+//
+// Задачи, проекты, метки, журнал и корзина принадлежат пользователям.
+// Хранилище видит данные одного пользователя: общего аккаунта (ID 0) или
+// того, для кого оно получено через ForUser, — и чужие задачи, открытые
+// ему через Share.
+type TaskStore interface {
+	UserStore
+	ForUser(userID int) TaskStore
+
+	AddTask(task Task) (int, error)
+	Tasks(limit int) ([]Task, error)
+	TasksWithFilter(filter TaskFilter, page Page) ([]Task, string, error)
+	GetTask(id int) (Task, error)
+	UpdateTask(task Task) error
+	DeleteTask(id int) error
+	MarkDone(id int, now time.Time) error
+
+	TaskHistory(taskID int, limit int) ([]Completion, error)
+	RecentCompletions(limit int) ([]Completion, error)
+
+	Dependencies(taskID int) ([]Dependency, error)
+	AddDependency(taskID, dependsOn int) error
+	RemoveDependency(taskID, dependsOn int) error
+
+	Checklist(taskID int) ([]ChecklistItem, error)
+	AddChecklistItem(item ChecklistItem) (int, error)
+	UpdateChecklistItem(item ChecklistItem) error
+	DeleteChecklistItem(id int) error
+
+	Shares(taskID, projectID int) ([]Share, error)
+	AddShare(share Share) (int, error)
+	DeleteShare(id int) error
+
+	Projects(includeArchived bool) ([]Project, error)
+	GetProject(id int) (Project, error)
+	AddProject(p Project) (int, error)
+	UpdateProject(p Project) error
+	DeleteProject(id int, tasks ProjectTasks) error
+
+	Tags() ([]Tag, error)
+	RenameTag(oldName, newName string) error
+	DeleteTag(name string) error
+
+	Trash(limit int) ([]TrashItem, error)
+	RestoreTask(taskID int) (Task, error)
+
+	Migrate() (from, to int, err error)
+	Close() error
+}
+
+// UserStore — учётные записи пользователей.
+type UserStore interface {
+	AddUser(login, password string) (int, error)
+	// Authenticate возвращает ErrInvalidCredentials, если логина нет или
+	// пароль не подходит.
+	Authenticate(login, password string) (User, error)
+	GetUser(id int) (User, error)
+	HasUsers() (bool, error)
+
+	// Сессии пользователя, для которого получено хранилище.
+	AddSession(sess Session, refreshToken string) error
+	RotateSession(refreshToken, newRefreshToken string, next Session) (Session, error)
+	Sessions() ([]Session, error)
+	RevokeSession(id string) error
+	RevokeToken(jti string, expiresAt time.Time) error
+	TokenRevoked(jti string) (bool, error)
+
+	// Ключи API пользователя; APIKeyBySecret ищет среди ключей всех
+	// пользователей.
+	AddAPIKey(key APIKey, secret string) (int, error)
+	APIKeys() ([]APIKey, error)
+	RevokeAPIKey(id int) error
+	APIKeyBySecret(secret string) (APIKey, error)
+}
@@ -0,0 +1,204 @@
+// pkg/db/tags.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Tag — метка задачи и число задач с ней.
+type Tag struct {
+	ID    int    `json:"id,string"`
+	Name  string `json:"name"`
+	Tasks int    `json:"tasks,string"`
+}
+
+// tagFilter — условие «у задачи есть метки names»: все или, если matchAny,
+// хотя бы одна. Метки ищутся среди меток владельца задачи — у открытой
+// пользователю чужой задачи это метки её владельца.
+func tagFilter(names []string, matchAny bool) (clause string, args []interface{}) {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		// повтор метки не должен увеличивать число совпадений в HAVING
+		if !seen[name] {
+			seen[name] = true
+			args = append(args, name)
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	clause = "id IN (SELECT task_tags.task_id FROM task_tags" +
+		" JOIN tags ON tags.id = task_tags.tag_id" +
+		" WHERE tags.owner_id = scheduler.owner_id AND tags.name IN (" + placeholders + ")"
+	if matchAny {
+		return clause + ")", args
+	}
+	clause += " GROUP BY task_tags.task_id HAVING COUNT(*) = ?)"
+	return clause, append(args, len(args))
+}
+
+// setTaskTags заменяет метки задачи, создавая недостающие метки владельца.
+func setTaskTags(q querier, owner, taskID int, names []string) error {
+	if _, err := q.Exec("DELETE FROM task_tags WHERE task_id = ?", taskID); err != nil {
+		return err
+	}
+	for _, name := range names {
+		_, err := q.Exec(
+			"INSERT INTO tags (owner_id, name) VALUES (?, ?) ON CONFLICT (owner_id, name) DO NOTHING",
+			owner, name,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = q.Exec(
+			"INSERT INTO task_tags (task_id, tag_id) SELECT ?, id FROM tags WHERE owner_id = ? AND name = ?"+
+				" ON CONFLICT DO NOTHING",
+			taskID, owner, name,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags заполняет Tags у задач одним запросом.
+func loadTags(q querier, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	byID := make(map[int]*Task, len(tasks))
+	args := make([]interface{}, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+		args[i] = tasks[i].ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tasks)), ", ")
+
+	rows, err := q.Query(
+		"SELECT task_tags.task_id, tags.name FROM task_tags JOIN tags ON tags.id = task_tags.tag_id"+
+			" WHERE task_tags.task_id IN ("+placeholders+") ORDER BY tags.name",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			taskID int
+			name   string
+		)
+		if err := rows.Scan(&taskID, &name); err != nil {
+			return err
+		}
+		if t := byID[taskID]; t != nil {
+			t.Tags = append(t.Tags, name)
+		}
+	}
+	return rows.Err()
+}
+
+// Tags возвращает все метки пользователя по алфавиту.
+func (s *SQLStore) Tags() ([]Tag, error) {
+	rows, err := s.conn().Query(
+		"SELECT tags.id, tags.name, COUNT(task_tags.task_id) FROM tags"+
+			" LEFT JOIN task_tags ON task_tags.tag_id = tags.id"+
+			" WHERE tags.owner_id = ?"+
+			" GROUP BY tags.id, tags.name ORDER BY tags.name",
+		s.owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Tasks); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// RenameTag переименовывает метку. Если метка newName уже есть, метки
+// объединяются.
+func (s *SQLStore) RenameTag(oldName, newName string) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	oldID, err := s.tagID(tx, oldName)
+	if err != nil {
+		return err
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	newID, err := s.tagID(tx, newName)
+	if err != nil && err != errTagNotFound {
+		return err
+	}
+	if err == errTagNotFound {
+		if _, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", newName, oldID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO task_tags (task_id, tag_id) SELECT task_id, ? FROM task_tags WHERE tag_id = ? ON CONFLICT DO NOTHING",
+		newID, oldID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := deleteTag(tx, oldID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTag удаляет метку у всех задач.
+func (s *SQLStore) DeleteTag(name string) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := s.tagID(tx, name)
+	if err != nil {
+		return err
+	}
+	if err := deleteTag(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+var errTagNotFound = errors.New("tag not found")
+
+func (s *SQLStore) tagID(q querier, name string) (int, error) {
+	var id int
+	err := q.QueryRow("SELECT id FROM tags WHERE owner_id = ? AND name = ?", s.owner, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errTagNotFound
+	}
+	return id, err
+}
+
+func deleteTag(q querier, id int) error {
+	if _, err := q.Exec("DELETE FROM task_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	_, err := q.Exec("DELETE FROM tags WHERE id = ?", id)
+	return err
+}
@@ -3,6 +3,7 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -11,115 +12,396 @@ import (
 )
 
 type Task struct {
-	ID      int    `json:"id,string"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Repeat  string `json:"repeat"`
+	ID          int    `json:"id,string"`
+	Date        string `json:"date"`
+	Title       string `json:"title"`
+	Comment     string `json:"comment"`
+	Repeat      string `json:"repeat"`
+	RepeatCount int    `json:"repeat_count,string,omitempty"`
+	RepeatUntil string `json:"repeat_until,omitempty"`
+	Time        string `json:"time,omitempty"`
+	Duration    int    `json:"duration,string,omitempty"`
+	// Priority — важность задачи от 0 (обычная) до MaxPriority.
+	Priority int `json:"priority,string,omitempty"`
+	// ProjectID — проект задачи; 0 — без проекта.
+	ProjectID int `json:"project_id,string,omitempty"`
+	// CreatedAt — время создания задачи в RFC 3339, UTC.
+	CreatedAt string `json:"created_at,omitempty"`
+	// Tags — метки задачи. При обновлении nil оставляет метки прежними,
+	// пустой список их снимает.
+	Tags []string `json:"tags,omitempty"`
+	// Shared — роль пользователя в чужой задаче, открытой ему: RoleViewer
+	// или RoleEditor; у своих задач пусто.
+	Shared string `json:"shared,omitempty"`
+	// Blocked — у задачи есть невыполненные предварительные задачи.
+	Blocked bool `json:"blocked,string,omitempty"`
+	// Checklist — пункты чек-листа; заполняется только для одной задачи
+	// (GetTask) и меняется через отдельные методы хранилища.
+	Checklist []ChecklistItem `json:"checklist,omitempty"`
+
+	// Snippet — фрагмент текста с выделенными совпадениями, только в результатах поиска.
+	Snippet string `json:"snippet,omitempty"`
+
+	// OwnerID — владелец задачи; заполняется только для одной задачи (getTask).
+	OwnerID int `json:"-"`
+}
+
+const taskColumns = "id, date, title, comment, repeat, repeat_count, repeat_until, time, duration, priority, project_id, created_at"
+
+// MaxPriority — наибольшая важность задачи.
+const MaxPriority = 3
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier — общее у *sql.DB и *sql.Tx, чтобы одни и те же запросы
+// выполнялись как отдельно, так и внутри транзакции.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
-func buildWhereClause(searchText, searchDate string) (where string, args []interface{}) {
-	var clauses []string
+// scanTask читает столбцы taskColumns и следующие за ними столбцы в extra.
+func scanTask(row rowScanner, extra ...interface{}) (Task, error) {
+	var t Task
+	dest := []interface{}{&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat,
+		&t.RepeatCount, &t.RepeatUntil, &t.Time, &t.Duration, &t.Priority, &t.ProjectID, &t.CreatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	return t, err
+}
 
-	if searchDate != "" {
+// RepeatEnd возвращает условие окончания серии повторений задачи.
+func (t Task) RepeatEnd() utils.RepeatEnd {
+	return utils.RepeatEnd{Count: t.RepeatCount, Until: t.RepeatUntil}
+}
+
+// buildWhereClause переводит фильтр в условие WHERE с параметрами. Текст
+// пользователя попадает в запрос только через параметры. Если у СУБД есть
+// полнотекстовый индекс, положительные текстовые условия проверяет
+// соединение из ftsJoin, а здесь остаются только отрицания.
+func buildWhereClause(d *dialect, user int, filter TaskFilter) (where string, args []interface{}) {
+	access, args := accessClause(user)
+	clauses := []string{access}
+
+	if filter.Date != "" {
 		clauses = append(clauses, "date = ?")
-		args = append(args, searchDate)
+		args = append(args, filter.Date)
+	}
+	if filter.From != "" {
+		clauses = append(clauses, "date >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		clauses = append(clauses, "date <= ?")
+		args = append(args, filter.To)
+	}
+	if filter.Repeat != nil {
+		if *filter.Repeat {
+			clauses = append(clauses, "repeat <> ''")
+		} else {
+			clauses = append(clauses, "repeat = ''")
+		}
 	}
 
-	if searchText != "" {
-		like := "%" + searchText + "%"
-		clauses = append(clauses, "(title LIKE ? OR comment LIKE ?)")
-		args = append(args, like, like)
+	if filter.Project != nil {
+		clauses = append(clauses, "project_id = ?")
+		args = append(args, *filter.Project)
+	}
+	if filter.Shared != nil {
+		if *filter.Shared {
+			clauses = append(clauses, "owner_id <> ?")
+		} else {
+			clauses = append(clauses, "owner_id = ?")
+		}
+		args = append(args, user)
+	}
+	if filter.Blocked != nil {
+		if *filter.Blocked {
+			clauses = append(clauses, blockedExpr)
+		} else {
+			clauses = append(clauses, "NOT "+blockedExpr)
+		}
+	}
+	if len(filter.Tags) > 0 {
+		clause, tagArgs := tagFilter(filter.Tags, filter.AnyTag)
+		clauses = append(clauses, clause)
+		args = append(args, tagArgs...)
 	}
 
-	if len(clauses) > 0 {
-		where = " WHERE " + strings.Join(clauses, " AND ")
+	for _, term := range filter.Terms {
+		var clause string
+		if match := ftsTerm(term); d.fts && match != "" {
+			if !term.Negate {
+				continue
+			}
+			clause = "id IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)"
+			args = append(args, match)
+		} else {
+			var likes []string
+			for _, field := range term.fields() {
+				likes = append(likes, field+" "+d.like+` ? ESCAPE '\'`)
+				args = append(args, "%"+escapeLike(term.Text)+"%")
+			}
+			clause = "(" + strings.Join(likes, " OR ") + ")"
+		}
+		if term.Negate {
+			clause = "NOT " + clause
+		}
+		clauses = append(clauses, clause)
 	}
-	return where, args
+
+	return " WHERE " + strings.Join(clauses, " AND "), args
 }
 
-func AddTask(task Task) (int, error) {
-	if DB == nil {
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (s *SQLStore) AddTask(task Task) (int, error) {
+	if s.db == nil {
 		return 0, sql.ErrConnDone
 	}
 
-	res, err := DB.Exec(
-		"INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)",
-		task.Date, task.Title, task.Comment, task.Repeat,
-	)
+	tx, err := s.begin()
 	if err != nil {
 		return 0, err
 	}
-	id, _ := res.LastInsertId()
-	return int(id), nil
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(
+		`INSERT INTO scheduler (date, title, comment, repeat, repeat_count, repeat_until, time, duration,
+			priority, project_id, created_at, owner_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		task.Date, task.Title, task.Comment, task.Repeat, task.RepeatCount, task.RepeatUntil,
+		task.Time, task.Duration, task.Priority, task.ProjectID, time.Now().UTC().Format(time.RFC3339), s.owner,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := setTaskTags(tx, s.owner, id, task.Tags); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
 }
 
-func Tasks(limit int) ([]Task, error) {
-	return TasksWithFilter(limit, "", "")
+func (s *SQLStore) Tasks(limit int) ([]Task, error) {
+	tasks, _, err := s.TasksWithFilter(TaskFilter{}, Page{Limit: limit})
+	return tasks, err
 }
 
-func TasksWithFilter(limit int, searchText, searchDate string) ([]Task, error) {
-	whereClause, args := buildWhereClause(searchText, searchDate)
+// TasksWithFilter возвращает страницу задач, подходящих под filter, и курсор
+// следующей страницы ("" — страница последняя). Страницы выбираются по ключу
+// сортировки, а не через OFFSET.
+func (s *SQLStore) TasksWithFilter(filter TaskFilter, page Page) ([]Task, string, error) {
+	match := ""
+	if s.dialect.fts {
+		match = ftsMatch(filter.Terms)
+	}
+
+	sortBy := page.Sort
+	if sortBy == "" {
+		sortBy = SortDate
+		if match != "" {
+			sortBy = SortRank
+		}
+	}
+	if _, ok := sortKeys[sortBy]; !ok {
+		return nil, "", fmt.Errorf("%w: неизвестная сортировка %q", ErrInvalidPage, sortBy)
+	}
+	if sortBy == SortRank && match == "" {
+		return nil, "", fmt.Errorf("%w: сортировка по релевантности доступна только при поиске по тексту", ErrInvalidPage)
+	}
+
+	var after *cursor
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.Sort != sortBy || c.Desc != page.Desc || len(c.Keys) != len(sortKeys[sortBy]) {
+			return nil, "", fmt.Errorf("%w: курсор получен для другой сортировки", ErrInvalidPage)
+		}
+		after = &c
+	}
+
+	role, args := roleColumn(s.owner)
+	columns := taskColumns + ", " + role + ", " + blockedExpr
+	from := " FROM scheduler"
+	if match != "" {
+		join, joinArgs := ftsJoin(match)
+		columns += ", fts_rank, fts_snippet"
+		from += join
+		args = append(args, joinArgs...)
+	}
 
-	query := "SELECT id, date, title, comment, repeat FROM scheduler" +
-		whereClause +
-		" ORDER BY date ASC"
+	whereClause, whereArgs := buildWhereClause(s.dialect, s.owner, filter)
+	args = append(args, whereArgs...)
 
-	if limit > 0 {
+	afterClause, afterArgs, orderBy := keyset(sortBy, page.Desc, after)
+	if afterClause != "" {
+		whereClause += " AND " + afterClause
+		args = append(args, afterArgs...)
+	}
+
+	query := "SELECT " + columns + from + whereClause + orderBy
+	if page.Limit > 0 {
+		// Лишняя строка показывает, есть ли следующая страница.
 		query += " LIMIT ?"
-		args = append(args, limit)
+		args = append(args, page.Limit+1)
 	}
 
-	rows, err := DB.Query(query, args...)
+	rows, err := s.conn().Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var tasks []Task
+	tasks := []Task{}
+	next := ""
+	var lastRank float64
 	for rows.Next() {
-		var t Task
-		var id int64
-		if err := rows.Scan(&id, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
-			return nil, err
+		if page.Limit > 0 && len(tasks) == page.Limit {
+			last := tasks[len(tasks)-1]
+			next = newCursor(sortBy, page.Desc, last, lastRank).encode()
+			break
+		}
+
+		var (
+			t       Task
+			shared  string
+			blocked bool
+		)
+		if match != "" {
+			var snippet string
+			t, err = scanTask(rows, &shared, &blocked, &lastRank, &snippet)
+			t.Snippet = highlight(snippet)
+		} else {
+			t, err = scanTask(rows, &shared, &blocked)
+		}
+		if err != nil {
+			return nil, "", err
 		}
-		t.ID = int(id)
+		t.Shared = shared
+		t.Blocked = blocked
 		tasks = append(tasks, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	rows.Close()
 
-	return tasks, rows.Err()
+	if err := loadTags(s.conn(), tasks); err != nil {
+		return nil, "", err
+	}
+	return tasks, next, nil
 }
 
-func GetTask(id int) (Task, error) {
-	var t Task
-	err := DB.QueryRow("SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?", id).
-		Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
+func (s *SQLStore) GetTask(id int) (Task, error) {
+	t, err := s.getTask(s.conn(), id)
+	if err != nil {
+		return t, err
+	}
+	err = s.conn().QueryRow("SELECT "+blockedExpr+" FROM scheduler WHERE id = ?", id).Scan(&t.Blocked)
+	return t, err
+}
+
+// getTask читает задачу, которую видит пользователь, вместе с метками и
+// чек-листом. Чужая задача, не открытая ему, для него не существует.
+func (s *SQLStore) getTask(q querier, id int) (Task, error) {
+	role, args := roleColumn(s.owner)
+	access, accessArgs := accessClause(s.owner)
+	args = append(append(args, id), accessArgs...)
+
+	var (
+		owner  int
+		shared string
+	)
+	t, err := scanTask(
+		q.QueryRow("SELECT "+taskColumns+", owner_id, "+role+" FROM scheduler WHERE id = ? AND "+access, args...),
+		&owner, &shared,
+	)
+	t.OwnerID = owner
+	t.Shared = shared
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return t, fmt.Errorf("task not found")
 		}
 		return t, err
 	}
-	return t, nil
+	tasks := []Task{t}
+	if err := loadTags(q, tasks); err != nil {
+		return t, err
+	}
+	t = tasks[0]
+	t.Checklist, err = checklist(q, id)
+	return t, err
 }
 
-func UpdateTask(task Task) error {
-	res, err := DB.Exec(`
+// UpdateTask меняет свою или открытую для изменения задачу. Проект чужой
+// задачи остаётся прежним.
+func (s *SQLStore) UpdateTask(task Task) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := s.editTask(tx, task.ID)
+	if err != nil {
+		return err
+	}
+	if old.Shared != "" {
+		task.ProjectID = old.ProjectID
+	}
+
+	res, err := tx.Exec(`
 		UPDATE scheduler 
-		SET date = ?, title = ?, comment = ?, repeat = ?
+		SET date = ?, title = ?, comment = ?, repeat = ?, repeat_count = ?, repeat_until = ?,
+			time = ?, duration = ?, priority = ?, project_id = ?
 		WHERE id = ?`,
-		task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+		task.Date, task.Title, task.Comment, task.Repeat, task.RepeatCount, task.RepeatUntil,
+		task.Time, task.Duration, task.Priority, task.ProjectID, task.ID)
 	if err != nil {
 		return err
 	}
 	if affected, _ := res.RowsAffected(); affected == 0 {
 		return fmt.Errorf("task not found")
 	}
-	return nil
+	if task.Tags != nil {
+		if err := setTaskTags(tx, old.OwnerID, task.ID, task.Tags); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteTask удаляет задачу, сохраняя её снимок в корзине на UndoWindow.
+// Удалить задачу может только владелец.
+func (s *SQLStore) DeleteTask(id int) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	task, err := s.ownTask(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.addTrash(tx, task, trashDelete, 0, time.Now()); err != nil {
+		return err
+	}
+	if err := deleteTask(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func DeleteTask(id int) error {
-	res, err := DB.Exec("DELETE FROM scheduler WHERE id = ?", id)
+func deleteTask(q querier, id int) error {
+	res, err := q.Exec("DELETE FROM scheduler WHERE id = ?", id)
 	if err != nil {
 		return err
 	}
@@ -129,26 +411,76 @@ func DeleteTask(id int) error {
 	return nil
 }
 
-func MarkDone(id int) error {
-	task, err := GetTask(id)
+// lockTask блокирует строку задачи до конца транзакции, чтобы несколько
+// экземпляров сервера не выполнили одно и то же повторение дважды: второй
+// дождётся первого и прочитает уже перенесённую дату. SQLite блокировок
+// строк не знает, но в ней запись и так идёт по очереди.
+func lockTask(tx *sqlTx, id int) error {
+	if !tx.d.rowLocks {
+		return nil
+	}
+	var locked int
+	err := tx.QueryRow("SELECT id FROM scheduler WHERE id = ? FOR UPDATE", id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		// отсутствующую задачу сообщит editTask
+		return nil
+	}
+	return err
+}
+
+// MarkDone отмечает задачу выполненной: однократная задача удаляется,
+// повторяющаяся переносится на ближайшую дату после now. В обоих случаях
+// в той же транзакции в журнал выполнения пишется запись о выполнении,
+// а прежнее состояние задачи сохраняется в корзине для отмены.
+func (s *SQLStore) MarkDone(id int, now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockTask(tx, id); err != nil {
+		return err
+	}
+	task, err := s.editTask(tx, id)
+	if err != nil {
+		return err
+	}
+	completionID, err := addCompletion(tx, task, now)
 	if err != nil {
 		return err
 	}
+	if err := s.addTrash(tx, task, trashDone, completionID, now); err != nil {
+		return err
+	}
+
 	if task.Repeat == "" {
-		return DeleteTask(id)
+		if err := deleteTask(tx, id); err != nil {
+			return err
+		}
+		return tx.Commit()
 	}
 
-	nextDate, err := utils.NextDate(time.Now(), task.Date, task.Repeat)
+	nextDate, end, err := utils.NextDateEnd(now, task.Date, task.Time, task.Repeat, task.RepeatEnd())
+	if errors.Is(err, utils.ErrSeriesEnded) {
+		if err := deleteTask(tx, id); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
 	if err != nil {
 		return err
 	}
 
-	res, err := DB.Exec("UPDATE scheduler SET date = ? WHERE id = ?", nextDate, id)
+	res, err := tx.Exec("UPDATE scheduler SET date = ?, repeat_count = ? WHERE id = ?", nextDate, end.Count, id)
 	if err != nil {
 		return err
 	}
 	if affected, _ := res.RowsAffected(); affected == 0 {
 		return fmt.Errorf("task not found")
 	}
-	return nil
-}
\ No newline at end of file
+	if err := resetChecklist(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,145 @@
+// pkg/db/trash.go
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	trashDelete = "delete"
+	trashDone   = "done"
+)
+
+// defaultUndoWindow — сколько времени по умолчанию удалённую или выполненную
+// задачу можно вернуть.
+const defaultUndoWindow = 10 * time.Minute
+
+// TrashItem — снимок задачи до удаления или выполнения.
+type TrashItem struct {
+	ID        int    `json:"id,string"`
+	Action    string `json:"action"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+	Task      Task   `json:"task"`
+}
+
+func (s *SQLStore) trashCutoff(now time.Time) string {
+	return now.Add(-s.UndoWindow).UTC().Format(time.RFC3339)
+}
+
+func (s *SQLStore) addTrash(q querier, task Task, action string, completionID int, now time.Time) error {
+	if _, err := q.Exec("DELETE FROM trash WHERE created_at < ?", s.trashCutoff(now)); err != nil {
+		return err
+	}
+
+	snapshot, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(
+		"INSERT INTO trash (task_id, action, task, completion_id, created_at, owner_id) VALUES (?, ?, ?, ?, ?, ?)",
+		task.ID, action, string(snapshot), completionID, now.UTC().Format(time.RFC3339), task.OwnerID,
+	)
+	return err
+}
+
+// Trash возвращает задачи, которые ещё можно восстановить, начиная с последних.
+func (s *SQLStore) Trash(limit int) ([]TrashItem, error) {
+	rows, err := s.conn().Query(
+		"SELECT id, action, task, created_at FROM trash WHERE owner_id = ? AND created_at >= ? ORDER BY id DESC LIMIT ?",
+		s.owner, s.trashCutoff(time.Now()), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []TrashItem{}
+	for rows.Next() {
+		var item TrashItem
+		var snapshot string
+		if err := rows.Scan(&item.ID, &item.Action, &snapshot, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(snapshot), &item.Task); err != nil {
+			return nil, err
+		}
+		if created, err := time.Parse(time.RFC3339, item.CreatedAt); err == nil {
+			item.ExpiresAt = created.Add(s.UndoWindow).Format(time.RFC3339)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// RestoreTask отменяет последнее удаление или выполнение задачи, если окно
+// отмены ещё не истекло. Для выполнения удаляется и запись журнала.
+func (s *SQLStore) RestoreTask(taskID int) (Task, error) {
+	var task Task
+
+	tx, err := s.begin()
+	if err != nil {
+		return task, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id           int
+		snapshot     string
+		completionID int
+	)
+	err = tx.QueryRow(
+		"SELECT id, task, completion_id FROM trash WHERE task_id = ? AND owner_id = ? AND created_at >= ?"+
+			" ORDER BY id DESC LIMIT 1",
+		taskID, s.owner, s.trashCutoff(time.Now()),
+	).Scan(&id, &snapshot, &completionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return task, fmt.Errorf("nothing to restore")
+		}
+		return task, err
+	}
+	if err := json.Unmarshal([]byte(snapshot), &task); err != nil {
+		return task, err
+	}
+	// Проект задачи мог быть удалён, пока она лежала в корзине.
+	if task.ProjectID != 0 {
+		if _, err := s.getProject(tx, task.ProjectID); err == errProjectNotFound {
+			task.ProjectID = 0
+		} else if err != nil {
+			return task, err
+		}
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO scheduler ("+taskColumns+", owner_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"+
+			" ON CONFLICT (id) DO UPDATE SET date = excluded.date, title = excluded.title,"+
+			" comment = excluded.comment, repeat = excluded.repeat, repeat_count = excluded.repeat_count,"+
+			" repeat_until = excluded.repeat_until, time = excluded.time, duration = excluded.duration,"+
+			" priority = excluded.priority, project_id = excluded.project_id, created_at = excluded.created_at",
+		task.ID, task.Date, task.Title, task.Comment, task.Repeat,
+		task.RepeatCount, task.RepeatUntil, task.Time, task.Duration, task.Priority, task.ProjectID, task.CreatedAt,
+		s.owner,
+	)
+	if err != nil {
+		return task, err
+	}
+	if err := setTaskTags(tx, s.owner, task.ID, task.Tags); err != nil {
+		return task, err
+	}
+	if err := setChecklist(tx, task.ID, task.Checklist); err != nil {
+		return task, err
+	}
+	if completionID > 0 {
+		if _, err := tx.Exec("DELETE FROM completions WHERE id = ?", completionID); err != nil {
+			return task, err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM trash WHERE id = ?", id); err != nil {
+		return task, err
+	}
+	return task, tx.Commit()
+}
@@ -0,0 +1,125 @@
+// pkg/db/users.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User — учётная запись. Задачи, созданные под общим паролем TODO_PASSWORD
+// и до появления учётных записей, принадлежат пользователю с ID 0.
+type User struct {
+	ID        int    `json:"id,string"`
+	Login     string `json:"login"`
+	CreatedAt string `json:"created_at"`
+	// PasswordHash — bcrypt-хеш пароля.
+	PasswordHash string `json:"-"`
+}
+
+const (
+	maxLoginLength    = 64
+	minPasswordLength = 8
+)
+
+var (
+	ErrUserExists         = errors.New("пользователь с таким логином уже есть")
+	ErrInvalidCredentials = errors.New("неверный логин или пароль")
+	errUserNotFound       = errors.New("user not found")
+)
+
+// dummyHash сравнивается с паролем, когда логин не найден, чтобы время ответа
+// не выдавало, существует ли пользователь.
+var dummyHash = []byte("$2a$10$3SnYfRCxpqIyqIm3NBc9HudcUIx/g1mtdOjmzTg0GUEGt0GfadDFK")
+
+const userColumns = "id, login, password_hash, created_at"
+
+func scanUser(row rowScanner) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Login, &u.PasswordHash, &u.CreatedAt)
+	return u, err
+}
+
+// AddUser создаёт учётную запись и возвращает её ID.
+func (s *SQLStore) AddUser(login, password string) (int, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return 0, errors.New("login is empty")
+	}
+	if utf8.RuneCountInString(login) > maxLoginLength {
+		return 0, fmt.Errorf("логин длиннее %d символов", maxLoginLength)
+	}
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return 0, fmt.Errorf("пароль короче %d символов", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := userByLogin(tx, login); err == nil {
+		return 0, ErrUserExists
+	} else if err != errUserNotFound {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow(
+		"INSERT INTO users (login, password_hash, created_at) VALUES (?, ?, ?) RETURNING id",
+		login, string(hash), time.Now().UTC().Format(time.RFC3339),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Authenticate проверяет логин и пароль и возвращает пользователя.
+func (s *SQLStore) Authenticate(login, password string) (User, error) {
+	u, err := userByLogin(s.conn(), strings.TrimSpace(login))
+	if err == errUserNotFound {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return u, ErrInvalidCredentials
+	}
+	if err != nil {
+		return u, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+func (s *SQLStore) GetUser(id int) (User, error) {
+	u, err := scanUser(s.conn().QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return u, errUserNotFound
+	}
+	return u, err
+}
+
+// HasUsers сообщает, заведена ли хотя бы одна учётная запись.
+func (s *SQLStore) HasUsers() (bool, error) {
+	var exists bool
+	err := s.conn().QueryRow("SELECT EXISTS (SELECT 1 FROM users)").Scan(&exists)
+	return exists, err
+}
+
+func userByLogin(q querier, login string) (User, error) {
+	u, err := scanUser(q.QueryRow("SELECT "+userColumns+" FROM users WHERE login = ?", login))
+	if err == sql.ErrNoRows {
+		return u, errUserNotFound
+	}
+	return u, err
+}
@@ -8,11 +8,12 @@ import (
     "os"
 
     "github.com/Myagchiev/final-project/pkg/api"
+    "github.com/Myagchiev/final-project/pkg/db"
 )
 
 const defaultPort = "7540"
 
-func Run() {
+func Run(store db.TaskStore) {
     webDir := "./web"
 
     port := os.Getenv("TODO_PORT")
@@ -20,13 +21,14 @@ func Run() {
         port = defaultPort
     }
 
-    api.Init()
+    mux := http.NewServeMux()
+    api.New(store).Register(mux)
 
-    http.Handle("/", http.FileServer(http.Dir(webDir)))
+    mux.Handle("/", http.FileServer(http.Dir(webDir)))
 
     log.Printf("Сервер запущен на порту %s...\n", port)
 
-    err := http.ListenAndServe(":"+port, nil)
+    err := http.ListenAndServe(":"+port, mux)
     if err != nil {
         log.Fatalf("Ошибка запуска сервера: %v", err)
     }
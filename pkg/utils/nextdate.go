@@ -8,109 +8,227 @@ import (
     "time"
 )
 
-const DateLayout = "20060102"
+const (
+    DateLayout = "20060102"
+    TimeLayout = "15:04"
+)
+
+// shortRule — разобранное правило повторения в коротком формате d/y/w/m.
+type shortRule struct {
+    kind      string
+    interval  int
+    weekdays  map[int]bool
+    monthDays map[int]bool
+    ordinals  map[weekdayOrdinal]bool
+    months    map[int]bool
+}
+
+// RepeatEnd — условие окончания серии повторений.
+type RepeatEnd struct {
+    // Count — сколько повторений осталось, включая текущую дату; 0 — без ограничения.
+    Count int
+    // Until — последняя допустимая дата серии в формате DateLayout; "" — без ограничения.
+    Until string
+}
 
 func NextDate(now time.Time, dstart string, repeat string) (string, error) {
-    date, err := time.Parse(DateLayout, dstart)
+    next, _, err := nextOccurrence(now, dstart, 0, repeat)
     if err != nil {
-        return "", errors.New("неверная дата dstart")
+        return "", err
     }
+    return next.Format(DateLayout), nil
+}
 
+// ParseTimeOfDay разбирает время в формате TimeLayout и возвращает смещение
+// от начала суток. Пустая строка означает задачу на весь день.
+func ParseTimeOfDay(tod string) (time.Duration, error) {
+    if tod == "" {
+        return 0, nil
+    }
+    t, err := time.Parse(TimeLayout, tod)
+    if err != nil || t.Format(TimeLayout) != tod {
+        return 0, errors.New("неверное время, ожидается ЧЧ:ММ")
+    }
+    return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// NextDateEnd работает как NextDate, но учитывает время задачи tod и условие
+// окончания серии: повторение сегодня подходит, если его время ещё не прошло.
+// Возвращает остаток условия после перехода на новую дату или ErrSeriesEnded,
+// если повторений больше нет.
+func NextDateEnd(now time.Time, dstart string, tod string, repeat string, end RepeatEnd) (string, RepeatEnd, error) {
+    if err := ValidateRepeatEnd(end); err != nil {
+        return "", end, err
+    }
+    offset, err := ParseTimeOfDay(tod)
+    if err != nil {
+        return "", end, err
+    }
+
+    next, steps, err := nextOccurrence(now, dstart, offset, repeat)
+    if err != nil {
+        return "", end, err
+    }
+
+    if end.Until != "" && next.Format(DateLayout) > end.Until {
+        return "", end, ErrSeriesEnded
+    }
+    if end.Count > 0 {
+        end.Count -= steps
+        if end.Count <= 0 {
+            return "", end, ErrSeriesEnded
+        }
+    }
+    return next.Format(DateLayout), end, nil
+}
+
+// ValidateRepeatEnd проверяет поля условия окончания серии.
+func ValidateRepeatEnd(end RepeatEnd) error {
+    if end.Count < 0 {
+        return errors.New("недопустимое число повторений")
+    }
+    if end.Until != "" {
+        if _, err := time.Parse(DateLayout, end.Until); err != nil {
+            return errors.New("неверная дата окончания повторений")
+        }
+    }
+    return nil
+}
+
+// nextOccurrence возвращает ближайшую дату, которая со смещением offset
+// наступает после now, и число шагов правила, которые пришлось сделать
+// от dstart, включая найденную дату.
+func nextOccurrence(now time.Time, dstart string, offset time.Duration, repeat string) (time.Time, int, error) {
+    it, err := NewOccurrences(dstart, repeat, RepeatEnd{})
+    if err != nil {
+        return time.Time{}, 0, err
+    }
+    now = wallClock(now)
+    for steps := 1; ; steps++ {
+        date, ok := it.Next()
+        if !ok {
+            return time.Time{}, 0, it.Err()
+        }
+        if date.Add(offset).After(now) {
+            return date, steps, nil
+        }
+    }
+}
+
+// wallClock переносит показания часов now в UTC, чтобы сравнивать их с датами
+// правила, которые разбираются как полночь UTC. Так «сегодня» определяется
+// часовым поясом now, а не UTC.
+func wallClock(now time.Time) time.Time {
+    return time.Date(now.Year(), now.Month(), now.Day(),
+        now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
+}
+
+// ValidateRepeat проверяет правило повторения, не вычисляя дат.
+// Пустое правило допустимо и означает однократную задачу.
+func ValidateRepeat(repeat string) error {
     if repeat == "" {
-        return "", errors.New("пустое правило повторения")
+        return nil
     }
+    if IsRRule(repeat) {
+        _, err := parseRRule(repeat)
+        return err
+    }
+    _, err := parseShortRule(repeat)
+    return err
+}
 
+func parseShortRule(repeat string) (shortRule, error) {
     parts := strings.Split(strings.TrimSpace(repeat), " ")
     if len(parts) == 0 {
-        return "", errors.New("некорректное правило повторения")
+        return shortRule{}, errors.New("некорректное правило повторения")
     }
 
-    rule := parts[0]
+    rule := shortRule{kind: parts[0]}
 
-    switch rule {
+    switch rule.kind {
     case "d":
         if len(parts) < 2 {
-            return "", errors.New("не указан интервал в днях")
+            return rule, errors.New("не указан интервал в днях")
         }
         days, err := strconv.Atoi(parts[1])
         if err != nil || days <= 0 || days > 400 {
-            return "", errors.New("недопустимый интервал дней")
-        }
-        for {
-            date = date.AddDate(0, 0, days)
-            if date.After(now) {
-                return date.Format(DateLayout), nil
-            }
+            return rule, errors.New("недопустимый интервал дней")
         }
+        rule.interval = days
 
     case "y":
-        for {
-            date = date.AddDate(1, 0, 0)
-            if date.After(now) {
-                return date.Format(DateLayout), nil
-            }
-        }
 
     case "w":
         if len(parts) < 2 {
-            return "", errors.New("не указаны дни недели")
+            return rule, errors.New("не указаны дни недели")
         }
         targetDays, err := parseDays(parts[1])
         if err != nil {
-            return "", err
-        }
-        for {
-            date = date.AddDate(0, 0, 1)
-            wd := int(date.Weekday())
-            if wd == 0 {
-                wd = 7
-            }
-            if targetDays[wd] && date.After(now) {
-                return date.Format(DateLayout), nil
-            }
+            return rule, err
         }
+        rule.weekdays = targetDays
 
     case "m":
         if len(parts) < 2 {
-            return "", errors.New("не указаны дни месяца")
+            return rule, errors.New("не указаны дни месяца")
         }
         dayStr := parts[1]
         monthStr := ""
         if len(parts) >= 3 {
             monthStr = parts[2]
         }
-        targetDays, err := parseMonthDays(dayStr)
+        targetDays, targetWeekdays, err := parseMonthDays(dayStr)
         if err != nil {
-            return "", err
+            return rule, err
         }
         targetMonths, err := parseMonths(monthStr)
         if err != nil {
-            return "", err
+            return rule, err
         }
-        for {
-            date = date.AddDate(0, 0, 1)
-            _, m, d := date.Year(), date.Month(), date.Day()
-            if !targetMonths[int(m)] {
-                continue
-            }
-            matched := false
-            if targetDays[d] {
-                matched = true
-            }
-            if targetDays[-1] && d == getLastDay(date) {
-                matched = true
-            }
-            if targetDays[-2] && d == getSecondLastDay(date) {
-                matched = true
-            }
-            if matched && date.After(now) {
-                return date.Format(DateLayout), nil
-            }
+        rule.monthDays = targetDays
+        rule.ordinals = targetWeekdays
+        rule.months = targetMonths
+        if !canMatch(rule.matches) {
+            return rule, ErrNoOccurrences
         }
 
     default:
-        return "", errors.New("неподдерживаемый формат правила")
+        return rule, errors.New("неподдерживаемый формат правила")
     }
+
+    return rule, nil
+}
+
+// next возвращает ближайшую дату повторения строго после date.
+func (r shortRule) next(date time.Time) (time.Time, error) {
+    switch r.kind {
+    case "d":
+        return date.AddDate(0, 0, r.interval), nil
+    case "y":
+        return date.AddDate(1, 0, 0), nil
+    }
+    return scanDays(date, maxScanDays, r.matches)
+}
+
+func (r shortRule) matches(date time.Time) bool {
+    if r.kind == "w" {
+        return r.weekdays[weekdayNumber(date)]
+    }
+
+    if !r.months[int(date.Month())] {
+        return false
+    }
+    d := date.Day()
+    if r.monthDays[d] {
+        return true
+    }
+    if r.monthDays[-1] && d == getLastDay(date) {
+        return true
+    }
+    if r.monthDays[-2] && d == getSecondLastDay(date) {
+        return true
+    }
+    return matchWeekdayOrdinal(date, r.ordinals)
 }
 
 func getLastDay(t time.Time) int {
@@ -125,6 +243,45 @@ func getSecondLastDay(t time.Time) int {
     return last
 }
 
+// weekdayOrdinal — «N-й день недели W в месяце», N = 1..4 или -1 (последний).
+type weekdayOrdinal struct {
+    n       int
+    weekday int
+}
+
+func weekdayNumber(t time.Time) int {
+    wd := int(t.Weekday())
+    if wd == 0 {
+        wd = 7
+    }
+    return wd
+}
+
+func matchWeekdayOrdinal(t time.Time, targets map[weekdayOrdinal]bool) bool {
+    if len(targets) == 0 {
+        return false
+    }
+    wd := weekdayNumber(t)
+    d := t.Day()
+    if targets[weekdayOrdinal{n: (d-1)/7 + 1, weekday: wd}] {
+        return true
+    }
+    return d+7 > getLastDay(t) && targets[weekdayOrdinal{n: -1, weekday: wd}]
+}
+
+func parseWeekdayOrdinal(s string) (weekdayOrdinal, error) {
+    nStr, wdStr, _ := strings.Cut(s, ":")
+    n, err := strconv.Atoi(nStr)
+    if err != nil || (n != -1 && (n < 1 || n > 4)) {
+        return weekdayOrdinal{}, errors.New("недопустимый порядковый номер дня недели")
+    }
+    wd, err := strconv.Atoi(wdStr)
+    if err != nil || wd < 1 || wd > 7 {
+        return weekdayOrdinal{}, errors.New("недопустимый день недели")
+    }
+    return weekdayOrdinal{n: n, weekday: wd}, nil
+}
+
 func parseDays(s string) (map[int]bool, error) {
     days := make(map[int]bool)
     for _, p := range strings.Split(s, ",") {
@@ -144,13 +301,22 @@ func parseDays(s string) (map[int]bool, error) {
     return days, nil
 }
 
-func parseMonthDays(s string) (map[int]bool, error) {
+func parseMonthDays(s string) (map[int]bool, map[weekdayOrdinal]bool, error) {
     days := make(map[int]bool)
+    weekdays := make(map[weekdayOrdinal]bool)
     for _, p := range strings.Split(s, ",") {
         p = strings.TrimSpace(p)
         if p == "" {
             continue
         }
+        if strings.Contains(p, ":") {
+            wo, err := parseWeekdayOrdinal(p)
+            if err != nil {
+                return nil, nil, err
+            }
+            weekdays[wo] = true
+            continue
+        }
         if p == "-1" {
             days[-1] = true
             continue
@@ -161,14 +327,14 @@ func parseMonthDays(s string) (map[int]bool, error) {
         }
         d, err := strconv.Atoi(p)
         if err != nil || d < 1 || d > 31 {
-            return nil, errors.New("недопустимый день месяца")
+            return nil, nil, errors.New("недопустимый день месяца")
         }
         days[d] = true
     }
-    if len(days) == 0 {
-        return nil, errors.New("не указаны дни месяца")
+    if len(days) == 0 && len(weekdays) == 0 {
+        return nil, nil, errors.New("не указаны дни месяца")
     }
-    return days, nil
+    return days, weekdays, nil
 }
 
 func parseMonths(s string) (map[int]bool, error) {
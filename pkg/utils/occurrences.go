@@ -0,0 +1,143 @@
+// pkg/utils/occurrences.go
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// maxScanDays ограничивает поиск следующей даты перебором дней:
+	// за 8 лет гарантированно встречается любое 29 февраля.
+	maxScanDays = 366 * 8
+	// maxSteps — страховка от бесконечного перебора повторений.
+	maxSteps = 1_000_000
+	// за 28 лет календарь проходит все сочетания дня недели,
+	// числа месяца и високосного года
+	calendarCycleYears = 28
+)
+
+var (
+	ErrNoOccurrences = errors.New("правило повторения не даёт ни одной даты")
+	ErrTooManySteps  = errors.New("слишком много повторений до нужной даты")
+)
+
+// calendarCycleStart — начало 28-летнего цикла без пропуска високосного года.
+var calendarCycleStart = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Occurrences перебирает даты повторения правила, идущие после dstart.
+// Сама dstart считается первым повторением серии и не возвращается.
+type Occurrences struct {
+	date  time.Time
+	step  func(time.Time) (time.Time, error)
+	left  int // сколько дат ещё можно вернуть, -1 — без ограничения
+	until time.Time
+	steps int
+	err   error
+}
+
+func NewOccurrences(dstart string, repeat string, end RepeatEnd) (*Occurrences, error) {
+	date, err := time.Parse(DateLayout, dstart)
+	if err != nil {
+		return nil, errors.New("неверная дата dstart")
+	}
+	if repeat == "" {
+		return nil, errors.New("пустое правило повторения")
+	}
+	if err := ValidateRepeatEnd(end); err != nil {
+		return nil, err
+	}
+
+	it := &Occurrences{date: date, left: -1}
+	if end.Count > 0 {
+		it.left = end.Count - 1
+	}
+	if end.Until != "" {
+		it.until, _ = time.Parse(DateLayout, end.Until)
+	}
+
+	if IsRRule(repeat) {
+		rr, err := parseRRule(repeat)
+		if err != nil {
+			return nil, err
+		}
+		it.step = rr.step(date)
+		if rr.count > 0 && (it.left < 0 || rr.count-1 < it.left) {
+			it.left = rr.count - 1
+		}
+		if !rr.until.IsZero() && (it.until.IsZero() || rr.until.Before(it.until)) {
+			it.until = rr.until
+		}
+		return it, nil
+	}
+
+	rule, err := parseShortRule(repeat)
+	if err != nil {
+		return nil, err
+	}
+	it.step = rule.next
+	return it, nil
+}
+
+// Next возвращает следующую дату серии. false означает, что серия
+// закончилась или правило больше не даёт дат; причину возвращает Err.
+func (it *Occurrences) Next() (time.Time, bool) {
+	if it.err != nil {
+		return time.Time{}, false
+	}
+	if it.left == 0 {
+		it.err = ErrSeriesEnded
+		return time.Time{}, false
+	}
+	if it.steps >= maxSteps {
+		it.err = ErrTooManySteps
+		return time.Time{}, false
+	}
+	it.steps++
+
+	date, err := it.step(it.date)
+	if err != nil {
+		it.err = err
+		return time.Time{}, false
+	}
+	if !it.until.IsZero() && date.After(it.until) {
+		it.err = ErrSeriesEnded
+		return time.Time{}, false
+	}
+
+	it.date = date
+	if it.left > 0 {
+		it.left--
+	}
+	return date, true
+}
+
+func (it *Occurrences) Err() error {
+	return it.err
+}
+
+// scanDays перебирает дни после date и возвращает первый, для которого
+// match вернул true. Если за limit дней совпадений нет, возвращает
+// ErrNoOccurrences.
+func scanDays(date time.Time, limit int, match func(time.Time) bool) (time.Time, error) {
+	for i := 0; i < limit; i++ {
+		date = date.AddDate(0, 0, 1)
+		if match(date) {
+			return date, nil
+		}
+	}
+	return date, ErrNoOccurrences
+}
+
+// canMatch проверяет, что match срабатывает хотя бы на одну дату
+// календарного цикла. Так правила вроде «31 февраля» отсекаются при
+// разборе, а не зацикливают поиск.
+func canMatch(match func(time.Time) bool) bool {
+	end := calendarCycleStart.AddDate(calendarCycleYears, 0, 0)
+	for date := calendarCycleStart; date.Before(end); date = date.AddDate(0, 0, 1) {
+		if match(date) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,505 @@
+// pkg/utils/rrule.go
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRulePrefix отличает правило RFC 5545 от короткого формата d/y/w/m.
+const RRulePrefix = "RRULE:"
+
+const rruleMaxInterval = 1000
+
+var ErrSeriesEnded = errors.New("серия повторений завершена")
+
+var rruleWeekdays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+var rruleFreqs = map[string]int{
+	"DAILY":   1,
+	"WEEKLY":  7,
+	"MONTHLY": 31,
+	"YEARLY":  366,
+}
+
+// rrule — подмножество RFC 5545: FREQ, INTERVAL, BYDAY, BYMONTHDAY,
+// BYMONTH, COUNT, UNTIL. Неделя всегда начинается с понедельника.
+type rrule struct {
+	freq       string
+	interval   int
+	byDay      []weekdayOrdinal // n == 0 — каждый такой день недели
+	byMonthDay []int
+	byMonth    []int
+	count      int
+	until      time.Time
+}
+
+func IsRRule(repeat string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(repeat)), RRulePrefix)
+}
+
+func rruleError(format string, args ...any) error {
+	return fmt.Errorf("некорректное правило RRULE: "+format, args...)
+}
+
+func parseRRule(s string) (rrule, error) {
+	s = strings.TrimSpace(s)
+	if !IsRRule(s) {
+		return rrule{}, rruleError("ожидается префикс %s", RRulePrefix)
+	}
+	s = s[len(RRulePrefix):]
+
+	r := rrule{interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return r, rruleError("параметр %q без значения", key)
+		}
+		if seen[key] {
+			return r, rruleError("параметр %s указан дважды", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			if _, ok := rruleFreqs[value]; !ok {
+				return r, rruleError("неподдерживаемая частота %s", value)
+			}
+			r.freq = value
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+			if err != nil || r.interval < 1 || r.interval > rruleMaxInterval {
+				return r, rruleError("недопустимый INTERVAL %s", value)
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(value)
+			if err != nil || r.count < 1 {
+				return r, rruleError("недопустимый COUNT %s", value)
+			}
+		case "UNTIL":
+			r.until, err = parseRRuleUntil(value)
+			if err != nil {
+				return r, rruleError("недопустимый UNTIL %s", value)
+			}
+		case "BYDAY":
+			r.byDay, err = parseRRuleByDay(value)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseRRuleInts(value, -31, 31)
+		case "BYMONTH":
+			r.byMonth, err = parseRRuleInts(value, 1, 12)
+		case "WKST":
+			if value != "MO" {
+				return r, rruleError("поддерживается только WKST=MO")
+			}
+		default:
+			return r, rruleError("неподдерживаемый параметр %s", key)
+		}
+		if err != nil {
+			return r, rruleError("%s: %v", key, err)
+		}
+	}
+
+	if r.freq == "" {
+		return r, rruleError("не указан FREQ")
+	}
+	if r.count > 0 && !r.until.IsZero() {
+		return r, rruleError("COUNT и UNTIL нельзя указывать вместе")
+	}
+	if r.freq == "WEEKLY" && len(r.byMonthDay) > 0 {
+		return r, rruleError("BYMONTHDAY не сочетается с FREQ=WEEKLY")
+	}
+	for _, wo := range r.byDay {
+		if wo.n == 0 {
+			continue
+		}
+		if r.freq != "MONTHLY" && r.freq != "YEARLY" {
+			return r, rruleError("номер дня недели в BYDAY допустим только для MONTHLY и YEARLY")
+		}
+		if r.freq == "MONTHLY" && (wo.n < -5 || wo.n > 5) {
+			return r, rruleError("недопустимый номер дня недели %d", wo.n)
+		}
+	}
+	if !canMatch(r.matchFilters) {
+		return r, ErrNoOccurrences
+	}
+	return r, nil
+}
+
+func parseRRuleUntil(value string) (time.Time, error) {
+	if len(value) > len(DateLayout) {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			t, err = time.Parse("20060102T150405", value)
+		}
+		if err != nil {
+			return t, err
+		}
+		return truncateDate(t), nil
+	}
+	return time.Parse(DateLayout, value)
+}
+
+func parseRRuleByDay(value string) ([]weekdayOrdinal, error) {
+	var res []weekdayOrdinal
+	for _, p := range strings.Split(value, ",") {
+		if len(p) < 2 {
+			return nil, fmt.Errorf("недопустимый день недели %q", p)
+		}
+		code, numStr := p[len(p)-2:], p[:len(p)-2]
+		wd := 0
+		for i, c := range rruleWeekdays {
+			if c != "" && c == code {
+				wd = i
+			}
+		}
+		if wd == 0 {
+			return nil, fmt.Errorf("недопустимый день недели %q", p)
+		}
+		n := 0
+		if numStr != "" {
+			var err error
+			n, err = strconv.Atoi(numStr)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("недопустимый номер дня недели %q", p)
+			}
+		}
+		res = append(res, weekdayOrdinal{n: n, weekday: wd})
+	}
+	return res, nil
+}
+
+func parseRRuleInts(value string, min, max int) ([]int, error) {
+	var res []int
+	for _, p := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(p)
+		if err != nil || v == 0 || v < min || v > max {
+			return nil, fmt.Errorf("недопустимое значение %q", p)
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func truncateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rrule) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, 0, len(r.byDay))
+		for _, wo := range r.byDay {
+			d := rruleWeekdays[wo.weekday]
+			if wo.n != 0 {
+				d = strconv.Itoa(wo.n) + d
+			}
+			days = append(days, d)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.byMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.byMonthDay))
+	}
+	if len(r.byMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.byMonth))
+	}
+	if r.count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.count))
+	}
+	if !r.until.IsZero() {
+		parts = append(parts, "UNTIL="+r.until.Format(DateLayout))
+	}
+	return RRulePrefix + strings.Join(parts, ";")
+}
+
+func joinInts(list []int) string {
+	strs := make([]string, 0, len(list))
+	for _, v := range list {
+		strs = append(strs, strconv.Itoa(v))
+	}
+	return strings.Join(strs, ",")
+}
+
+// inPeriod проверяет, что date попадает в период, кратный INTERVAL от dstart.
+func (r rrule) inPeriod(dstart, date time.Time) bool {
+	var diff int
+	switch r.freq {
+	case "DAILY":
+		diff = int(date.Sub(dstart).Hours() / 24)
+	case "WEEKLY":
+		weekStart := func(t time.Time) time.Time {
+			return t.AddDate(0, 0, 1-weekdayNumber(t))
+		}
+		diff = int(weekStart(date).Sub(weekStart(dstart)).Hours() / 24 / 7)
+	case "MONTHLY":
+		diff = (date.Year()-dstart.Year())*12 + int(date.Month()) - int(dstart.Month())
+	case "YEARLY":
+		diff = date.Year() - dstart.Year()
+	}
+	return diff%r.interval == 0
+}
+
+func (r rrule) matches(dstart, date time.Time) bool {
+	if !r.inPeriod(dstart, date) || !r.matchFilters(date) {
+		return false
+	}
+
+	if len(r.byMonthDay) == 0 && len(r.byDay) == 0 {
+		switch r.freq {
+		case "WEEKLY":
+			return date.Weekday() == dstart.Weekday()
+		case "MONTHLY":
+			return date.Day() == dstart.Day()
+		case "YEARLY":
+			return date.Day() == dstart.Day() &&
+				(len(r.byMonth) > 0 || date.Month() == dstart.Month())
+		}
+	}
+	return true
+}
+
+// matchFilters проверяет BYMONTH, BYMONTHDAY и BYDAY, не зависящие от dstart.
+func (r rrule) matchFilters(date time.Time) bool {
+	if len(r.byMonth) > 0 && !containsInt(r.byMonth, int(date.Month())) {
+		return false
+	}
+	if len(r.byMonthDay) > 0 && !r.matchMonthDay(date) {
+		return false
+	}
+	return len(r.byDay) == 0 || r.matchDay(date)
+}
+
+func (r rrule) matchMonthDay(date time.Time) bool {
+	d, last := date.Day(), getLastDay(date)
+	for _, md := range r.byMonthDay {
+		if md == d || (md < 0 && last+1+md == d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rrule) matchDay(date time.Time) bool {
+	wd := weekdayNumber(date)
+	for _, wo := range r.byDay {
+		if wo.weekday != wd {
+			continue
+		}
+		if wo.n == 0 {
+			return true
+		}
+		// номер дня считается внутри месяца, а для YEARLY без BYMONTH — внутри года
+		pos, total := date.Day(), getLastDay(date)
+		if r.freq == "YEARLY" && len(r.byMonth) == 0 {
+			pos = date.YearDay()
+			total = time.Date(date.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		}
+		if wo.n > 0 && (pos-1)/7+1 == wo.n {
+			return true
+		}
+		if wo.n < 0 && (total-pos)/7+1 == -wo.n {
+			return true
+		}
+	}
+	return false
+}
+
+// step возвращает функцию перехода к следующей дате серии с началом в dstart.
+func (r rrule) step(dstart time.Time) func(time.Time) (time.Time, error) {
+	limit := r.interval*rruleFreqs[r.freq] + maxScanDays
+	return func(date time.Time) (time.Time, error) {
+		return scanDays(date, limit, func(t time.Time) bool {
+			return r.matches(dstart, t)
+		})
+	}
+}
+
+// SplitRRuleEnd выносит COUNT и UNTIL из правила RRULE в RepeatEnd, чтобы
+// условие окончания хранилось отдельно от правила и не пересчитывалось
+// от каждой новой даты задачи. Короткие правила возвращаются без изменений.
+func SplitRRuleEnd(repeat string) (string, RepeatEnd, error) {
+	if !IsRRule(repeat) {
+		return repeat, RepeatEnd{}, nil
+	}
+	r, err := parseRRule(repeat)
+	if err != nil {
+		return "", RepeatEnd{}, err
+	}
+	if r.count == 0 && r.until.IsZero() {
+		return repeat, RepeatEnd{}, nil
+	}
+
+	end := RepeatEnd{Count: r.count}
+	if !r.until.IsZero() {
+		end.Until = r.until.Format(DateLayout)
+	}
+	r.count = 0
+	r.until = time.Time{}
+	return r.String(), end, nil
+}
+
+// ToRRule переводит правило короткого формата в строку RRULE.
+// Правило RRULE возвращается в нормализованном виде. Перевод точный,
+// кроме «y» для задачи на 29 февраля: «y» переносит её на 1 марта
+// невисокосного года, а FREQ=YEARLY пропускает такие годы.
+func ToRRule(repeat string) (string, error) {
+	if IsRRule(repeat) {
+		r, err := parseRRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		return r.String(), nil
+	}
+
+	rule, err := parseShortRule(repeat)
+	if err != nil {
+		return "", err
+	}
+
+	r := rrule{interval: 1}
+	switch rule.kind {
+	case "d":
+		r.freq = "DAILY"
+		r.interval = rule.interval
+	case "y":
+		r.freq = "YEARLY"
+	case "w":
+		r.freq = "WEEKLY"
+		for _, wd := range sortedKeys(rule.weekdays) {
+			r.byDay = append(r.byDay, weekdayOrdinal{weekday: wd})
+		}
+	case "m":
+		if len(rule.monthDays) > 0 && len(rule.ordinals) > 0 {
+			return "", errors.New("правило с днями месяца и днями недели одновременно не выражается через RRULE")
+		}
+		r.freq = "MONTHLY"
+		days := sortedKeys(rule.monthDays)
+		// в RRULE отрицательные дни идут после положительных
+		sort.SliceStable(days, func(i, j int) bool { return days[i] > 0 && days[j] < 0 })
+		r.byMonthDay = days
+		r.byDay = sortedOrdinals(rule.ordinals)
+		if len(rule.months) < 12 {
+			r.byMonth = sortedKeys(rule.months)
+		}
+	}
+	return r.String(), nil
+}
+
+// FromRRule переводит правило RRULE в короткий формат d/y/w/m,
+// если оно в нём выразимо с теми же датами. Правила с COUNT и UNTIL
+// не переводятся: окончание серии хранится в отдельных полях задачи.
+// FREQ=YEARLY переводится в «y» с той же оговоркой про 29 февраля,
+// что и в ToRRule.
+func FromRRule(repeat string) (string, error) {
+	r, err := parseRRule(repeat)
+	if err != nil {
+		return "", err
+	}
+	errNotShort := errors.New("правило RRULE не выражается в коротком формате")
+
+	if r.count > 0 || !r.until.IsZero() {
+		return "", errNotShort
+	}
+	if r.freq != "DAILY" && r.interval != 1 {
+		return "", errNotShort
+	}
+
+	switch r.freq {
+	case "DAILY":
+		if len(r.byDay) > 0 || len(r.byMonthDay) > 0 || len(r.byMonth) > 0 || r.interval > 400 {
+			return "", errNotShort
+		}
+		return "d " + strconv.Itoa(r.interval), nil
+
+	case "WEEKLY":
+		if len(r.byDay) == 0 || len(r.byMonth) > 0 {
+			return "", errNotShort
+		}
+		days := make([]int, 0, len(r.byDay))
+		for _, wo := range r.byDay {
+			days = append(days, wo.weekday)
+		}
+		sort.Ints(days)
+		return "w " + joinInts(days), nil
+
+	case "YEARLY":
+		if len(r.byDay) == 0 && len(r.byMonthDay) == 0 && len(r.byMonth) == 0 {
+			return "y", nil
+		}
+		if len(r.byMonth) == 0 {
+			return "", errNotShort
+		}
+	}
+
+	// MONTHLY и YEARLY с BYMONTH сводятся к правилу m
+	if (len(r.byDay) > 0) == (len(r.byMonthDay) > 0) {
+		return "", errNotShort
+	}
+	var tokens []string
+	for _, md := range r.byMonthDay {
+		if md < -2 {
+			return "", errNotShort
+		}
+		tokens = append(tokens, strconv.Itoa(md))
+	}
+	for _, wo := range r.byDay {
+		if wo.n == 0 || wo.n < -1 || wo.n > 4 {
+			return "", errNotShort
+		}
+		tokens = append(tokens, fmt.Sprintf("%d:%d", wo.n, wo.weekday))
+	}
+	short := "m " + strings.Join(tokens, ",")
+	if len(r.byMonth) > 0 {
+		months := append([]int(nil), r.byMonth...)
+		sort.Ints(months)
+		short += " " + joinInts(months)
+	}
+	return short, nil
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func sortedOrdinals(m map[weekdayOrdinal]bool) []weekdayOrdinal {
+	res := make([]weekdayOrdinal, 0, len(m))
+	for wo := range m {
+		res = append(res, wo)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].n != res[j].n {
+			// последний (-1) идёт после первого..четвёртого
+			return res[i].n > 0 && (res[j].n < 0 || res[i].n < res[j].n)
+		}
+		return res[i].weekday < res[j].weekday
+	})
+	return res
+}
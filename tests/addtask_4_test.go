@@ -91,6 +91,9 @@ func TestAddTask(t *testing.T) {
 		{"28.01.2024", "Заголовок", "", ""},
 		{"20240112", "Заголовок", "", "w"},
 		{"20240212", "Заголовок", "", "ooops"},
+		{"20990101", "Заголовок", "", "ooops"},
+		{"20990101", "Заголовок", "", "RRULE:FREQ=WEEKLY;BYMONTHDAY=1"},
+		{"20990101", "Заголовок", "", "m 30,31 2"},
 	}
 	for _, v := range tbl {
 		m, err := postJSON("api/task", map[string]any{
@@ -165,6 +168,7 @@ func TestAddTask(t *testing.T) {
 	if FullNextDate {
 		tbl = []task{
 			{"20240129", "Сходить в магазин", "", "w 1,3,5"},
+			{"20240129", "Ретро", "", "RRULE:FREQ=MONTHLY;BYDAY=-1FR"},
 		}
 		check()
 	}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keyRequest выполняет запрос с ключом API в заголовке Authorization.
+func keyRequest(t *testing.T, key, apipath string, values map[string]any, method string) (int, map[string]any) {
+	return authRequest(t, "", "Bearer "+key, apipath, values, method)
+}
+
+func TestAPIKeys(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	erin := createUser(t, db, "erin", "erin-password")
+	defer func() {
+		db.Exec("DELETE FROM scheduler WHERE owner_id = ?", erin)
+		db.Exec("DELETE FROM api_keys WHERE user_id = ?", erin)
+		db.Exec("DELETE FROM sessions WHERE user_id = ?", erin)
+		db.Exec("DELETE FROM users WHERE id = ?", erin)
+	}()
+	token := signIn(t, "erin", "erin-password")
+
+	code, ret := userRequest(t, token, "api/keys", map[string]any{"name": "backup", "read_only": true}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	readKey, _ := ret["key"].(string)
+	readID, _ := ret["id"].(string)
+	assert.NotEmpty(t, readKey)
+	code, ret = userRequest(t, token, "api/keys", map[string]any{"name": "sync"}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	writeKey, _ := ret["key"].(string)
+	assert.NotEmpty(t, writeKey)
+
+	code, _ = userRequest(t, token, "api/keys", map[string]any{"name": " "}, http.MethodPost)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// В базе хранится только хеш ключа.
+	var stored int
+	assert.NoError(t, db.Get(&stored, "SELECT COUNT(*) FROM api_keys WHERE key_hash IN (?, ?)", readKey, writeKey))
+	assert.Zero(t, stored)
+
+	// Ключ с правом записи создаёт задачу от имени владельца.
+	today := time.Now().Format(`20060102`)
+	code, ret = keyRequest(t, writeKey, "api/task", map[string]any{"date": today, "title": "Из скрипта"}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, ret["id"])
+	var owner int
+	assert.NoError(t, db.Get(&owner, "SELECT owner_id FROM scheduler WHERE id = ?", ret["id"]))
+	assert.Equal(t, erin, owner)
+
+	// Ключ только для чтения видит задачи, но не меняет их.
+	code, ret = keyRequest(t, readKey, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	tasks, _ := ret["tasks"].([]any)
+	assert.Len(t, tasks, 1)
+	code, _ = keyRequest(t, readKey, "api/task", map[string]any{"date": today, "title": "Нельзя"}, http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// Ключами управляют только после входа.
+	code, _ = keyRequest(t, writeKey, "api/keys", nil, http.MethodGet)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// Схема в заголовке Authorization не зависит от регистра.
+	code, _ = authRequest(t, "", "bearer "+readKey, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+
+	// С ключом нельзя завершить сессии владельца.
+	code, _ = keyRequest(t, writeKey, "api/sessions", nil, http.MethodGet)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = keyRequest(t, writeKey, "api/sessions?id=any", nil, http.MethodDelete)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = keyRequest(t, writeKey, "api/signout", nil, http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = userRequest(t, token, "api/sessions", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, ret = userRequest(t, token, "api/keys", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	keys, _ := ret["keys"].([]any)
+	if assert.Len(t, keys, 2) {
+		for _, k := range keys {
+			k := k.(map[string]any)
+			assert.NotEmpty(t, k["last_used_at"])
+			assert.Nil(t, k["key"])
+			if k["id"] == readID {
+				assert.Equal(t, true, k["read_only"])
+				assert.Equal(t, readKey[:len(k["prefix"].(string))], k["prefix"])
+			}
+		}
+	}
+
+	// Отозванный ключ перестаёт действовать.
+	code, _ = userRequest(t, token, "api/keys?id="+readID, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = keyRequest(t, readKey, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = userRequest(t, token, "api/keys?id="+readID, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = keyRequest(t, "pk_неверный", "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
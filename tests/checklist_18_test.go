@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type checklistItem struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Position string `json:"position"`
+	Done     bool   `json:"done"`
+}
+
+func getChecklist(t *testing.T, taskID string) []checklistItem {
+	body, err := requestJSON("api/task?id="+taskID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var task struct {
+		Checklist []checklistItem `json:"checklist"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &task))
+	return task.Checklist
+}
+
+func checklistTitles(items []checklistItem) []string {
+	titles := []string{}
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func addChecklistItem(t *testing.T, taskID, title, position string) string {
+	ret, err := postJSON("api/task/checklist", map[string]any{
+		"task_id": taskID, "title": title, "position": position,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"])
+	id, _ := ret["id"].(string)
+	return id
+}
+
+func TestChecklist(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Подготовить релиз",
+		repeat: "d 7",
+	})
+
+	build := addChecklistItem(t, id, "build", "0")
+	addChecklistItem(t, id, "changelog", "0")
+	deploy := addChecklistItem(t, id, "deploy", "0")
+	tests := addChecklistItem(t, id, "tests", "2")
+	assert.Equal(t, []string{"build", "tests", "changelog", "deploy"}, checklistTitles(getChecklist(t, id)))
+
+	ret, err := postJSON("api/task/checklist", map[string]any{
+		"id": deploy, "title": "deploy", "position": "1",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/checklist", map[string]any{
+		"id": build, "title": "build", "done": true,
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	ret, err = postJSON("api/task/checklist?id="+tests, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	items := getChecklist(t, id)
+	assert.Equal(t, []string{"deploy", "build", "changelog"}, checklistTitles(items))
+	for i, item := range items {
+		assert.Equal(t, []string{"1", "2", "3"}[i], item.Position)
+		assert.Equal(t, item.Title == "build", item.Done, item.Title)
+	}
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	for _, item := range getChecklist(t, id) {
+		assert.False(t, item.Done, "Чек-лист повторяющейся задачи сбрасывается после выполнения")
+	}
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, id, ret["id"])
+	for _, item := range getChecklist(t, id) {
+		assert.Equal(t, item.Title == "build", item.Done, item.Title)
+	}
+
+	for _, item := range []map[string]any{
+		{"task_id": id, "title": " "},
+		{"task_id": "999999", "title": "нет задачи"},
+		{"title": "без задачи"},
+	} {
+		ret, err = postJSON("api/task/checklist", item, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "%v", item)
+	}
+
+	// Удаляем в обход API, чтобы задача с чек-листом не попала в корзину.
+	_, err = db.Exec("DELETE FROM scheduler WHERE id = ?", id)
+	assert.NoError(t, err)
+	var left int
+	assert.NoError(t, db.Get(&left, "SELECT COUNT(*) FROM checklist WHERE task_id = ?", id))
+	assert.Zero(t, left)
+}
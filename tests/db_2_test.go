@@ -11,11 +11,19 @@ import (
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID          int64  `db:"id"`
+	Date        string `db:"date"`
+	Title       string `db:"title"`
+	Comment     string `db:"comment"`
+	Repeat      string `db:"repeat"`
+	RepeatCount int    `db:"repeat_count"`
+	RepeatUntil string `db:"repeat_until"`
+	Time        string `db:"time"`
+	Duration    int    `db:"duration"`
+	Priority    int    `db:"priority"`
+	ProjectID   int    `db:"project_id"`
+	CreatedAt   string `db:"created_at"`
+	OwnerID     int    `db:"owner_id"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addDependency(t *testing.T, id, dependsOn string) map[string]any {
+	ret, err := postJSON("api/task/deps?id="+id+"&depends_on="+dependsOn, nil, http.MethodPost)
+	assert.NoError(t, err)
+	return ret
+}
+
+func TestDependencies(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	review := addTask(t, task{date: today, title: "Зависимость: код-ревью"})
+	deploy := addTask(t, task{date: today, title: "Зависимость: деплой"})
+	announce := addTask(t, task{date: today, title: "Зависимость: анонс"})
+	defer func() {
+		for _, id := range []string{review, deploy, announce} {
+			db.Exec("DELETE FROM scheduler WHERE id = ?", id)
+			db.Exec("DELETE FROM task_deps WHERE task_id = ? OR depends_on = ?", id, id)
+		}
+	}()
+
+	assert.Empty(t, addDependency(t, deploy, review))
+	assert.Empty(t, addDependency(t, announce, deploy))
+	assert.NotEmpty(t, addDependency(t, review, announce)["error"])
+	assert.NotEmpty(t, addDependency(t, review, review)["error"])
+
+	assert.Equal(t, "true", getTask(t, deploy)["blocked"])
+	assert.Empty(t, getTask(t, review)["blocked"])
+
+	search := url.Values{"search": {"Зависимость"}, "sort": {"id"}}
+	assert.Equal(t, []string{"Зависимость: код-ревью", "Зависимость: деплой", "Зависимость: анонс"},
+		pageTitles(t, "api/tasks", search))
+	search.Set("blocked", "hide")
+	assert.Equal(t, []string{"Зависимость: код-ревью"}, pageTitles(t, "api/tasks", search))
+	search.Set("blocked", "only")
+	assert.Equal(t, []string{"Зависимость: деплой", "Зависимость: анонс"}, pageTitles(t, "api/tasks", search))
+
+	ret, err := postJSON("api/task/done?id="+deploy, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Contains(t, ret["error"], "Зависимость: код-ревью")
+
+	ret, err = postJSON("api/task/done?id="+review, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, getTask(t, deploy)["blocked"])
+	assert.Equal(t, "true", getTask(t, announce)["blocked"])
+
+	ret, err = postJSON("api/task/done?id="+announce+"&force=1", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, announce)
+
+	ret, err = postJSON("api/task/deps?id="+deploy+"&depends_on="+review, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/deps?id="+deploy+"&depends_on="+review, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestRepeatingDependencies(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	review := addTask(t, task{date: today, title: "Зависимость: ревью релиза", repeat: "d 1"})
+	deploy := addTask(t, task{date: today, title: "Зависимость: выкладка", repeat: "d 1"})
+	defer func() {
+		for _, id := range []string{review, deploy} {
+			db.Exec("DELETE FROM scheduler WHERE id = ?", id)
+			db.Exec("DELETE FROM task_deps WHERE task_id = ? OR depends_on = ?", id, id)
+			db.Exec("DELETE FROM completions WHERE task_id = ?", id)
+			db.Exec("DELETE FROM trash WHERE task_id = ?", id)
+		}
+	}()
+	assert.Empty(t, addDependency(t, deploy, review))
+
+	for i := 0; i < 2; i++ {
+		// Каждое повторение выкладки ждёт своего ревью.
+		assert.Equal(t, "true", getTask(t, deploy)["blocked"], "цикл %d", i)
+		ret, err := postJSON("api/task/done?id="+deploy, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "цикл %d", i)
+
+		ret, err = postJSON("api/task/done?id="+review, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+		assert.Empty(t, getTask(t, deploy)["blocked"], "цикл %d", i)
+
+		ret, err = postJSON("api/task/done?id="+deploy, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+}
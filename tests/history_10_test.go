@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getCompletions(t *testing.T, apipath string) []map[string]string {
+	body, err := requestJSON(apipath, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m map[string][]map[string]string
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m["completions"]
+}
+
+func TestHistory(t *testing.T) {
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Полить цветы",
+		repeat: "d 2",
+	})
+
+	dates := []string{now.Format(`20060102`), now.AddDate(0, 0, 2).Format(`20060102`)}
+	for range dates {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	history := getCompletions(t, "api/task/history?id="+id)
+	assert.Len(t, history, 2)
+	if len(history) == 2 {
+		assert.Equal(t, dates[1], history[0]["date"])
+		assert.Equal(t, dates[0], history[1]["date"])
+		assert.Equal(t, id, history[0]["task_id"])
+		assert.NotEmpty(t, history[0]["done_at"])
+	}
+
+	once := addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Вынести мусор",
+	})
+	ret, err := postJSON("api/task/done?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, once)
+
+	history = getCompletions(t, "api/task/history?id="+once)
+	assert.Len(t, history, 1)
+
+	feed := getCompletions(t, "api/completed?limit=1")
+	assert.Len(t, feed, 1)
+	if len(feed) == 1 {
+		assert.Equal(t, once, feed[0]["task_id"])
+		assert.Equal(t, "Вынести мусор", feed[0]["title"])
+	}
+
+	_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+}
@@ -74,6 +74,27 @@ func TestNextDate(t *testing.T) {
 		{"20240126", "w 7", "20240128"},
 		{"20230126", "w 4,5", "20240201"},
 		{"20230226", "w 8,4,5", ""},
+		{"20240126", "m 2:2", "20240213"},
+		{"20240126", "m -1:5", "20240223"},
+		{"20240101", "m 1:1 3,9", "20240304"},
+		{"20240126", "m 1,-1:7", "20240128"},
+		{"20240126", "m 5:2", ""},
+		{"20240126", "m 1:8", ""},
+		{"20240126", "RRULE:FREQ=DAILY;INTERVAL=7", "20240202"},
+		{"20240126", "RRULE:FREQ=WEEKLY;BYDAY=MO,FR", "20240129"},
+		{"20240126", "RRULE:FREQ=MONTHLY;BYDAY=-1FR", "20240223"},
+		{"20240101", "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=1MO", "20240304"},
+		{"20240101", "RRULE:FREQ=MONTHLY;COUNT=3", "20240201"},
+		{"20240101", "RRULE:FREQ=MONTHLY;COUNT=1", ""},
+		{"20240101", "RRULE:FREQ=MONTHLY;UNTIL=20240115", ""},
+		{"20240126", "RRULE:FREQ=SOMETIMES", ""},
+		{"20240126", "m 30,31 2", ""},
+		{"20240126", "m 31 4,6,9,11", ""},
+		{"20240126", "m 29 2", "20240229"},
+		{"20240301", "m 29 2", "20280229"},
+		{"20240126", "RRULE:FREQ=MONTHLY;BYMONTHDAY=30;BYMONTH=2", ""},
+		{"20240126", "RRULE:FREQ=MONTHLY;BYMONTHDAY=1;BYDAY=2MO", ""},
+		{"20230101", "RRULE:FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29", ""},
 	}
 	check()
 }
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nextDates struct {
+	date   string
+	repeat string
+	params string
+	want   []string
+}
+
+func TestNextDates(t *testing.T) {
+	tbl := []nextDates{
+		{"20240126", "d 7", "limit=3", []string{"20240202", "20240209", "20240216"}},
+		{"20240126", "m 1,-1 2,5", "limit=4", []string{"20240201", "20240229", "20240501", "20240531"}},
+		{"20240126", "w 1", "from=20240210&to=20240301", []string{"20240212", "20240219", "20240226"}},
+		{"20240126", "d 10", "count=3", []string{"20240205", "20240215"}},
+		{"20240126", "y", "until=20240601", []string{}},
+		{"20240126", "m 31 2", "", nil},
+		{"20240126", "ooops", "", nil},
+		{"20240126", "d 1", "limit=1000", nil},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdates?now=20240126&date=%s&repeat=%s&%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat), v.params)
+		body, err := getBody(urlPath)
+		assert.NoError(t, err)
+
+		var m map[string]any
+		err = json.Unmarshal(body, &m)
+		assert.NoError(t, err)
+		if v.want == nil {
+			assert.NotEmpty(t, m["error"], "Ожидается ошибка для %v", v)
+			continue
+		}
+
+		var resp struct {
+			Dates []string `json:"dates"`
+		}
+		err = json.Unmarshal(body, &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, resp.Dates, "%v", v)
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tasksPage struct {
+	Tasks []map[string]string `json:"tasks"`
+	Next  string              `json:"next"`
+	Error string              `json:"error"`
+}
+
+func getPage(t *testing.T, params url.Values) tasksPage {
+	body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var page tasksPage
+	assert.NoError(t, json.Unmarshal(body, &page))
+	return page
+}
+
+// allPages проходит по страницам, пока сервер возвращает курсор next.
+func allPages(t *testing.T, params url.Values) []string {
+	var titles []string
+	for i := 0; i < 10; i++ {
+		page := getPage(t, params)
+		assert.Empty(t, page.Error)
+		for _, task := range page.Tasks {
+			titles = append(titles, task["title"])
+		}
+		if page.Next == "" {
+			return titles
+		}
+		params.Set("cursor", page.Next)
+	}
+	t.Error("Слишком много страниц")
+	return titles
+}
+
+func TestPagination(t *testing.T) {
+	if !Search {
+		return
+	}
+	dates := []string{"20310105", "20310103", "20310107", "20310101", "20310106", "20310102", "20310104"}
+	var ids []string
+	for i, date := range dates {
+		ids = append(ids, addTask(t, task{date: date, title: fmt.Sprintf("Страничка %d", i+1)}))
+	}
+
+	byTitle := allPages(t, url.Values{"search": {"страничка"}, "sort": {"title"}, "limit": {"3"}})
+	assert.Equal(t, []string{"Страничка 1", "Страничка 2", "Страничка 3", "Страничка 4",
+		"Страничка 5", "Страничка 6", "Страничка 7"}, byTitle)
+
+	byDate := allPages(t, url.Values{"search": {"страничка"}, "sort": {"date"}, "order": {"desc"}, "limit": {"2"}})
+	assert.Equal(t, []string{"Страничка 3", "Страничка 5", "Страничка 1", "Страничка 7",
+		"Страничка 2", "Страничка 6", "Страничка 4"}, byDate)
+
+	byCreated := allPages(t, url.Values{"search": {"страничка"}, "sort": {"created"}, "limit": {"4"}})
+	assert.Equal(t, byTitle, byCreated)
+
+	byRank := allPages(t, url.Values{"search": {"страничка"}, "limit": {"3"}})
+	assert.ElementsMatch(t, byTitle, byRank)
+
+	first := getPage(t, url.Values{"search": {"страничка"}, "sort": {"title"}, "limit": {"3"}})
+	assert.NotEmpty(t, first.Next)
+	for _, params := range []url.Values{
+		{"search": {"страничка"}, "sort": {"id"}, "limit": {"3"}, "cursor": {first.Next}},
+		{"search": {"страничка"}, "sort": {"title"}, "order": {"desc"}, "cursor": {first.Next}},
+		{"cursor": {"не курсор"}},
+		{"sort": {"importance"}},
+		{"sort": {"rank"}},
+		{"order": {"up"}},
+		{"limit": {"1000"}},
+	} {
+		assert.NotEmpty(t, getPage(t, params).Error, "Ожидается ошибка для %v", params)
+	}
+
+	for _, id := range ids {
+		ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+}
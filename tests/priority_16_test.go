@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addPriorityTask(t *testing.T, date, title, priority string) string {
+	ret, err := postJSON("api/task", map[string]any{
+		"date":     date,
+		"title":    title,
+		"priority": priority,
+		"tags":     []string{"фокус"},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"])
+	id, _ := ret["id"].(string)
+	return id
+}
+
+// pageTitles проходит по всем страницам path и возвращает названия задач.
+func pageTitles(t *testing.T, path string, params url.Values) []string {
+	titles := []string{}
+	for i := 0; i < 10; i++ {
+		body, err := requestJSON(path+"?"+params.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp struct {
+			Tasks []struct {
+				Title    string `json:"title"`
+				Priority string `json:"priority"`
+			} `json:"tasks"`
+			Next  string `json:"next"`
+			Error string `json:"error"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		assert.Empty(t, resp.Error)
+		for _, task := range resp.Tasks {
+			titles = append(titles, task.Title)
+		}
+		if resp.Next == "" {
+			break
+		}
+		params.Set("cursor", resp.Next)
+	}
+	return titles
+}
+
+func TestPriority(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	yesterday := now.AddDate(0, 0, -1).Format(`20060102`)
+
+	ids := []string{
+		addPriorityTask(t, today, "Сегодня, обычная", "0"),
+		addPriorityTask(t, today, "Сегодня, срочная", "3"),
+		addPriorityTask(t, today, "Вчера, срочная", "3"),
+		addPriorityTask(t, now.AddDate(0, 0, 5).Format(`20060102`), "Потом, срочная", "3"),
+		addPriorityTask(t, today, "Вчера, важная", "1"),
+	}
+	// Через API нельзя создать задачу в прошлом, поэтому просрочку задаём в базе.
+	for _, id := range []string{ids[2], ids[4]} {
+		_, err := db.Exec("UPDATE scheduler SET date = ? WHERE id = ?", yesterday, id)
+		assert.NoError(t, err)
+	}
+
+	focus := pageTitles(t, "api/focus", url.Values{"tags": {"фокус"}, "limit": {"2"}})
+	assert.Equal(t, []string{"Вчера, срочная", "Сегодня, срочная", "Вчера, важная", "Сегодня, обычная"}, focus)
+
+	byDate := pageTitles(t, "api/tasks", url.Values{"tags": {"фокус"}, "sort": {"date_priority"}, "limit": {"3"}})
+	assert.Equal(t, []string{"Вчера, срочная", "Вчера, важная", "Сегодня, срочная", "Сегодня, обычная",
+		"Потом, срочная"}, byDate)
+
+	for _, priority := range []string{"4", "-1"} {
+		ret, err := postJSON("api/task", map[string]any{
+			"date": today, "title": "Неверная важность", "priority": priority,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], priority)
+	}
+	ret, err := postJSON("api/task", map[string]any{
+		"id": ids[0], "date": today, "title": "Сегодня, обычная", "priority": "7",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	// Правка без поля priority, как из web/, важность не сбрасывает.
+	ret, err = postJSON("api/task", map[string]any{
+		"id": ids[1], "date": today, "title": "Сегодня, срочная", "comment": "", "repeat": "",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	var priority int
+	assert.NoError(t, db.Get(&priority, "SELECT priority FROM scheduler WHERE id = ?", ids[1]))
+	assert.Equal(t, 3, priority)
+
+	// Удаляем в обход API, чтобы задачи с метками не попали в корзину.
+	for _, id := range ids {
+		_, err := db.Exec("DELETE FROM scheduler WHERE id = ?", id)
+		assert.NoError(t, err)
+	}
+}
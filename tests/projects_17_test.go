@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addProject(t *testing.T, name, color string) string {
+	ret, err := postJSON("api/project", map[string]any{"name": name, "color": color}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"])
+	id, _ := ret["id"].(string)
+	return id
+}
+
+func addProjectTask(t *testing.T, projectID, title string) string {
+	ret, err := postJSON("api/task", map[string]any{
+		"date": "20330101", "title": title, "project_id": projectID,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"])
+	id, _ := ret["id"].(string)
+	return id
+}
+
+func projectNames(t *testing.T, params string) []string {
+	body, err := requestJSON("api/projects"+params, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp struct {
+		Projects []map[string]any `json:"projects"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	names := []string{}
+	for _, p := range resp.Projects {
+		names = append(names, p["name"].(string))
+	}
+	return names
+}
+
+func TestProjects(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, p := range []map[string]any{{"name": " "}, {"name": "Цвет", "color": "red"}} {
+		ret, err := postJSON("api/project", p, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "%v", p)
+	}
+
+	work := addProject(t, "Работа", "#ff0000")
+	home := addProject(t, "Дом", "")
+	report := addProjectTask(t, work, "Квартальный отчёт")
+	meeting := addProjectTask(t, work, "Планёрка")
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date": "20330101", "title": "Без проекта", "project_id": "999999",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	params := url.Values{"project": {work}}
+	assert.ElementsMatch(t, []string{"Квартальный отчёт", "Планёрка"}, pageTitles(t, "api/tasks", params))
+
+	// Правка без project_id, как из web/, оставляет задачу в проекте,
+	// а project_id "0" убирает её из проекта.
+	edit := map[string]any{"id": report, "date": "20330101", "title": "Квартальный отчёт", "comment": "", "repeat": ""}
+	ret, err = postJSON("api/task", edit, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.ElementsMatch(t, []string{"Квартальный отчёт", "Планёрка"}, pageTitles(t, "api/tasks", params))
+	edit["project_id"] = "0"
+	ret, err = postJSON("api/task", edit, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.ElementsMatch(t, []string{"Планёрка"}, pageTitles(t, "api/tasks", params))
+	edit["project_id"] = work
+	ret, err = postJSON("api/task", edit, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	body, err := requestJSON("api/project?id="+work, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var project map[string]any
+	assert.NoError(t, json.Unmarshal(body, &project))
+	assert.Equal(t, "2", project["tasks"])
+	assert.Equal(t, "#ff0000", project["color"])
+
+	ret, err = postJSON("api/project", map[string]any{"id": home, "name": "Дом", "archived": true}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.NotContains(t, projectNames(t, ""), "Дом")
+	assert.Contains(t, projectNames(t, "?archived=1"), "Дом")
+	ret, err = postJSON("api/task", map[string]any{
+		"date": "20330101", "title": "В архивный проект", "project_id": home,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	archive := addProject(t, "Архив", "")
+	ret, err = postJSON("api/project?id="+work, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Проект с задачами удаляется только с параметром tasks")
+
+	ret, err = postJSON("api/project?id="+work+"&tasks=move&to="+archive, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.NotContains(t, projectNames(t, ""), "Работа")
+	assert.ElementsMatch(t, []string{"Квартальный отчёт", "Планёрка"},
+		pageTitles(t, "api/tasks", url.Values{"project": {archive}}))
+
+	ret, err = postJSON("api/project?id="+archive+"&tasks=delete", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, report)
+	notFoundTask(t, meeting)
+
+	// Проект удалён, поэтому восстановленная задача остаётся без проекта.
+	ret, err = postJSON("api/task/restore?id="+report, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, report, ret["id"])
+	assert.Nil(t, ret["project_id"])
+
+	ret, err = postJSON("api/project?id="+home, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	_, err = db.Exec("DELETE FROM scheduler WHERE id = ?", report)
+	assert.NoError(t, err)
+}
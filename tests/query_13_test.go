@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQuery(t *testing.T) {
+	if !Search {
+		return
+	}
+	ids := []string{
+		addTask(t, task{date: "20300305", title: "Отчёт за март", comment: "квартальный", repeat: "d 30"}),
+		addTask(t, task{date: "20300320", title: "Сдать отчёт", comment: "", repeat: ""}),
+		addTask(t, task{date: "20300410", title: "Отчёт за апрель", comment: "", repeat: "d 30"}),
+		addTask(t, task{date: "20300315", title: "Позвонить бухгалтеру", comment: "про отчёт", repeat: "d 7"}),
+	}
+
+	tbl := []struct {
+		query string
+		want  []string
+	}{
+		{"repeat:yes from:01.03.2030 to:31.03.2030 отчёт", []string{"Отчёт за март", "Позвонить бухгалтеру"}},
+		{`title:отчёт -title:"за апрель"`, []string{"Отчёт за март", "Сдать отчёт"}},
+		{"comment:отчёт", []string{"Позвонить бухгалтеру"}},
+		{"repeat:no отчёт", []string{"Сдать отчёт"}},
+		{"-repeat:no отчёт to:31.03.2030", []string{"Отчёт за март", "Позвонить бухгалтеру"}},
+		{"отчёт -квартальный from:01.03.2030 to:30.04.2030", []string{"Сдать отчёт", "Отчёт за апрель", "Позвонить бухгалтеру"}},
+		{`"отчёт за" from:01.03.2030`, []string{"Отчёт за март", "Отчёт за апрель"}},
+		{"20.03.2030", []string{"Сдать отчёт"}},
+	}
+	for _, v := range tbl {
+		assert.ElementsMatch(t, v.want, searchTitles(t, v.query), v.query)
+	}
+
+	for _, query := range []string{"from:32.01.2030", "repeat:maybe", "title:", "-to:01.01.2030"} {
+		body, err := requestJSON("api/tasks?search="+url.QueryEscape(query), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.NotEmpty(t, m["error"], "Ожидается ошибка для %q", query)
+	}
+
+	for _, id := range ids {
+		ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func convertRepeat(t *testing.T, repeat string) map[string]any {
+	body, err := getBody("api/repeat?repeat=" + url.QueryEscape(repeat))
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m
+}
+
+func previewDates(t *testing.T, date, repeat string) []string {
+	body, err := getBody("api/nextdates?limit=40&now=" + date + "&date=" + date +
+		"&repeat=" + url.QueryEscape(repeat))
+	assert.NoError(t, err)
+	var resp struct {
+		Dates []string `json:"dates"`
+		Error string   `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.Empty(t, resp.Error, repeat)
+	return resp.Dates
+}
+
+func TestRepeatConvert(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		rrule  string
+	}{
+		{"d 1", "RRULE:FREQ=DAILY"},
+		{"d 400", "RRULE:FREQ=DAILY;INTERVAL=400"},
+		{"y", "RRULE:FREQ=YEARLY"},
+		{"w 1,3,7", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,SU"},
+		{"m 1,15,31", "RRULE:FREQ=MONTHLY;BYMONTHDAY=1,15,31"},
+		{"m 10,-2,-1", "RRULE:FREQ=MONTHLY;BYMONTHDAY=10,-2,-1"},
+		{"m 31 2,4,12", "RRULE:FREQ=MONTHLY;BYMONTHDAY=31;BYMONTH=2,4,12"},
+		{"m 2:2", "RRULE:FREQ=MONTHLY;BYDAY=2TU"},
+		{"m 1:1,-1:5 3,6", "RRULE:FREQ=MONTHLY;BYDAY=1MO,-1FR;BYMONTH=3,6"},
+	}
+	// 29 февраля не проверяется: «y» и FREQ=YEARLY для него расходятся.
+	dates := []string{"20240101", "20240131", "20240228", "20240531", "20241231"}
+
+	for _, v := range tbl {
+		m := convertRepeat(t, v.repeat)
+		assert.Equal(t, v.rrule, m["rrule"], v.repeat)
+		assert.Equal(t, v.repeat, m["repeat"], v.repeat)
+
+		m = convertRepeat(t, v.rrule)
+		assert.Equal(t, v.rrule, m["rrule"], v.rrule)
+		assert.Equal(t, v.repeat, m["repeat"], v.rrule)
+
+		for _, date := range dates {
+			assert.Equal(t, previewDates(t, date, v.repeat), previewDates(t, date, v.rrule),
+				"%s / %s от %s", v.repeat, v.rrule, date)
+		}
+	}
+
+	// Правила без точного аналога в коротком формате остаются только RRULE.
+	for _, rrule := range []string{
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"RRULE:FREQ=MONTHLY;BYDAY=MO",
+		"RRULE:FREQ=MONTHLY;BYMONTHDAY=-5",
+		"RRULE:FREQ=YEARLY;BYMONTH=3",
+	} {
+		m := convertRepeat(t, rrule)
+		assert.Nil(t, m["error"], rrule)
+		assert.NotEmpty(t, m["rrule"], rrule)
+		assert.Nil(t, m["repeat"], rrule)
+	}
+
+	for _, repeat := range []string{"", "ooops", "m 1,1:1", "RRULE:FREQ=HOURLY"} {
+		m := convertRepeat(t, repeat)
+		assert.NotEmpty(t, m["error"], repeat)
+	}
+}
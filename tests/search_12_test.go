@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func searchTitles(t *testing.T, search string) []string {
+	titles := []string{}
+	for _, task := range getTasks(t, url.QueryEscape(search)) {
+		titles = append(titles, task["title"])
+	}
+	return titles
+}
+
+func TestFullTextSearch(t *testing.T) {
+	if !Search {
+		return
+	}
+	date := time.Now().AddDate(0, 0, 3).Format(`20060102`)
+
+	water := addTask(t, task{date: date, title: "Полить кактусы", comment: "на подоконнике"})
+	pot := addTask(t, task{date: date, title: "Купить горшок", comment: "для кактуса и фикуса"})
+	many := addTask(t, task{date: date, title: "Кактус, кактус", comment: "кактусы везде"})
+
+	found := searchTitles(t, "КАКТУС")
+	assert.Len(t, found, 3)
+	if len(found) > 0 {
+		assert.Equal(t, "Кактус, кактус", found[0], "Ожидается сортировка по релевантности")
+	}
+
+	assert.Equal(t, []string{"Купить горшок"}, searchTitles(t, "кактус фикус"))
+	assert.Equal(t, []string{"Полить кактусы"}, searchTitles(t, `"на подоконнике"`))
+	assert.Empty(t, searchTitles(t, `"подоконнике на"`))
+
+	tasks := getTasks(t, url.QueryEscape("полить"))
+	assert.Len(t, tasks, 1)
+	if len(tasks) == 1 {
+		assert.True(t, strings.Contains(tasks[0]["snippet"], "<mark>Полить</mark>"), tasks[0]["snippet"])
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"id":      pot,
+		"date":    date,
+		"title":   "Купить лейку",
+		"comment": "",
+		"repeat":  "",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, searchTitles(t, "горшок"))
+	assert.Equal(t, []string{"Купить лейку"}, searchTitles(t, "лейк"))
+
+	for _, id := range []string{water, pot, many} {
+		ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	assert.Empty(t, searchTitles(t, "кактус"))
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func signInPair(t *testing.T, login, password string) (access, refresh string) {
+	code, ret := userRequest(t, "", "api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	access, _ = ret["token"].(string)
+	refresh, _ = ret["refresh_token"].(string)
+	assert.NotEmpty(t, access)
+	assert.NotEmpty(t, refresh)
+	return access, refresh
+}
+
+func refreshPair(t *testing.T, refresh string) (int, string, string) {
+	code, ret := userRequest(t, "", "api/refresh", map[string]any{"refresh_token": refresh}, http.MethodPost)
+	access, _ := ret["token"].(string)
+	next, _ := ret["refresh_token"].(string)
+	return code, access, next
+}
+
+func TestSessions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	carol := createUser(t, db, "carol", "carol-password")
+	defer func() {
+		db.Exec("DELETE FROM sessions WHERE user_id = ?", carol)
+		db.Exec("DELETE FROM users WHERE id = ?", carol)
+	}()
+
+	laptop, laptopRefresh := signInPair(t, "carol", "carol-password")
+	phone, phoneRefresh := signInPair(t, "carol", "carol-password")
+
+	code, ret := userRequest(t, laptop, "api/sessions", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	sessions, _ := ret["sessions"].([]any)
+	assert.Len(t, sessions, 2)
+	var phoneSession string
+	for _, s := range sessions {
+		s := s.(map[string]any)
+		if s["current"] != true {
+			phoneSession, _ = s["id"].(string)
+		}
+	}
+	assert.NotEmpty(t, phoneSession)
+
+	// Обновление выдаёт новую пару, а прежний access-токен отзывается.
+	code, access, next := refreshPair(t, laptopRefresh)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, laptopRefresh, next)
+	code, _ = userRequest(t, access, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = userRequest(t, laptop, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Повторное предъявление заменённого refresh-токена закрывает сессию.
+	code, _, _ = refreshPair(t, laptopRefresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _, _ = refreshPair(t, next)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = userRequest(t, access, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Сессию телефона можно закрыть с другого устройства.
+	laptop, _ = signInPair(t, "carol", "carol-password")
+	code, _ = userRequest(t, laptop, "api/sessions?id="+phoneSession, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = userRequest(t, phone, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _, _ = refreshPair(t, phoneRefresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = userRequest(t, laptop, "api/signout", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = userRequest(t, laptop, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestRefreshAfterPasswordChange(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	dave := createUser(t, db, "dave", "old-password")
+	defer func() {
+		db.Exec("DELETE FROM sessions WHERE user_id = ?", dave)
+		db.Exec("DELETE FROM users WHERE id = ?", dave)
+	}()
+
+	access, refresh := signInPair(t, "dave", "old-password")
+
+	// Пароль сменился: сессию, открытую со старым паролем, не продлить.
+	hash, err := bcrypt.GenerateFromPassword([]byte("new-password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	_, err = db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(hash), dave)
+	assert.NoError(t, err)
+
+	code, _, _ := refreshPair(t, refresh)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = userRequest(t, access, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	var sessions int
+	assert.NoError(t, db.Get(&sessions, "SELECT COUNT(*) FROM sessions WHERE user_id = ?", dave))
+	assert.Zero(t, sessions)
+
+	// Сессия с новым паролем продлевается как обычно.
+	_, refresh = signInPair(t, "dave", "new-password")
+	code, access, _ = refreshPair(t, refresh)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = userRequest(t, access, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShares(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	alice := createUser(t, db, "alice", "alice-password")
+	bob := createUser(t, db, "bob", "bob-password")
+	defer func() {
+		for _, table := range []string{"scheduler", "tags", "projects", "completions", "trash", "shares"} {
+			db.Exec("DELETE FROM "+table+" WHERE owner_id IN (?, ?)", alice, bob)
+		}
+		db.Exec("DELETE FROM users WHERE id IN (?, ?)", alice, bob)
+	}()
+	aliceToken := signIn(t, "alice", "alice-password")
+	bobToken := signIn(t, "bob", "bob-password")
+
+	today := time.Now().Format(`20060102`)
+	_, ret := userRequest(t, aliceToken, "api/task", map[string]any{"date": today, "title": "Релиз"}, http.MethodPost)
+	id, _ := ret["id"].(string)
+	_, ret = userRequest(t, aliceToken, "api/project", map[string]any{"name": "Команда"}, http.MethodPost)
+	project, _ := ret["id"].(string)
+	_, ret = userRequest(t, aliceToken, "api/task", map[string]any{
+		"date": today, "title": "Ретро", "project_id": project,
+	}, http.MethodPost)
+	retro, _ := ret["id"].(string)
+
+	share := func(token string, values map[string]any) (int, map[string]any) {
+		return userRequest(t, token, "api/share", values, http.MethodPost)
+	}
+	code, _ := share(aliceToken, map[string]any{"task_id": id, "login": "bob", "role": "owner"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = share(aliceToken, map[string]any{"task_id": id, "login": "nobody", "role": "viewer"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = share(bobToken, map[string]any{"task_id": id, "login": "bob", "role": "viewer"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, ret = share(aliceToken, map[string]any{"task_id": id, "login": "bob", "role": "viewer"})
+	assert.Equal(t, http.StatusOK, code)
+	shareID, _ := ret["id"].(string)
+	assert.NotEmpty(t, shareID)
+
+	_, ret = userRequest(t, bobToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.Equal(t, "Релиз", ret["title"])
+	assert.Equal(t, "viewer", ret["shared"])
+	_, ret = userRequest(t, bobToken, "api/tasks", nil, http.MethodGet)
+	if tasks, _ := ret["tasks"].([]any); assert.Len(t, tasks, 1) {
+		assert.Equal(t, "viewer", tasks[0].(map[string]any)["shared"])
+	}
+	_, ret = userRequest(t, bobToken, "api/tasks?shared=hide", nil, http.MethodGet)
+	assert.Empty(t, ret["tasks"])
+
+	update := map[string]any{"id": id, "date": today, "title": "Релиз 2.0"}
+	code, _ = userRequest(t, bobToken, "api/task", update, http.MethodPut)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = userRequest(t, bobToken, "api/task/done?id="+id, nil, http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// Повторный доступ тому же пользователю меняет роль.
+	_, ret = share(aliceToken, map[string]any{"task_id": id, "login": "bob", "role": "editor"})
+	assert.Equal(t, shareID, ret["id"])
+	code, _ = userRequest(t, bobToken, "api/task", update, http.MethodPut)
+	assert.Equal(t, http.StatusOK, code)
+	_, ret = userRequest(t, aliceToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.Equal(t, "Релиз 2.0", ret["title"])
+	assert.Nil(t, ret["shared"])
+	code, _ = userRequest(t, bobToken, "api/task?id="+id, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// Доступ к проекту открывает все его задачи.
+	_, ret = userRequest(t, bobToken, "api/task?id="+retro, nil, http.MethodGet)
+	assert.NotEmpty(t, ret["error"])
+	code, _ = share(aliceToken, map[string]any{"project_id": project, "login": "bob", "role": "viewer"})
+	assert.Equal(t, http.StatusOK, code)
+	_, ret = userRequest(t, bobToken, "api/task?id="+retro, nil, http.MethodGet)
+	assert.Equal(t, "Ретро", ret["title"])
+
+	// Редактор не может заставить задачу владельца ждать задачу, которую
+	// владелец не видит.
+	_, ret = userRequest(t, bobToken, "api/task", map[string]any{"date": today, "title": "Черновик"}, http.MethodPost)
+	draft, _ := ret["id"].(string)
+	code, ret = userRequest(t, bobToken, "api/task/deps?id="+id+"&depends_on="+draft, nil, http.MethodPost)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, ret["error"])
+	_, ret = userRequest(t, aliceToken, "api/task/deps?id="+id, nil, http.MethodGet)
+	assert.Empty(t, ret["dependencies"])
+	code, _ = userRequest(t, bobToken, "api/task/deps?id="+id+"&depends_on="+retro, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = userRequest(t, bobToken, "api/task/deps?id="+id+"&depends_on="+retro, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, ret = userRequest(t, aliceToken, "api/share?task_id="+id, nil, http.MethodGet)
+	if shares, _ := ret["shares"].([]any); assert.Len(t, shares, 1) {
+		assert.Equal(t, "bob", shares[0].(map[string]any)["login"])
+		assert.Equal(t, "editor", shares[0].(map[string]any)["role"])
+	}
+	code, _ = userRequest(t, bobToken, "api/share?task_id="+id, nil, http.MethodGet)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// Получатель может сам отказаться от доступа.
+	code, _ = userRequest(t, bobToken, "api/share?id="+shareID, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, code)
+	_, ret = userRequest(t, bobToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signInAttempt(t *testing.T, login, password string) (int, string) {
+	data, err := json.Marshal(map[string]string{"login": login, "password": password})
+	assert.NoError(t, err)
+	resp, err := http.Post(getURL("api/signin"), "application/json", bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("Retry-After")
+}
+
+func TestSignInLimit(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	dave := createUser(t, db, "dave", "dave-password")
+	defer func() {
+		db.Exec("DELETE FROM sessions WHERE user_id = ?", dave)
+		db.Exec("DELETE FROM users WHERE id = ?", dave)
+	}()
+
+	for i := 0; i < 3; i++ {
+		code, retry := signInAttempt(t, "dave", "guess")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Empty(t, retry)
+	}
+	code, retry := signInAttempt(t, "dave", "guess")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "1", retry)
+
+	// Пока действует задержка, не проверяется даже верный пароль.
+	code, retry = signInAttempt(t, "dave", "dave-password")
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Equal(t, "1", retry)
+
+	time.Sleep(1100 * time.Millisecond)
+	code, _ = signInAttempt(t, "dave", "dave-password")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Удачный вход обнуляет счётчик.
+	code, retry = signInAttempt(t, "nobody", "guess")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Empty(t, retry)
+	code, _ = signInAttempt(t, "dave", "dave-password")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestSignInConcurrentGuesses(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	frank := createUser(t, db, "frank", "frank-password")
+	defer func() {
+		db.Exec("DELETE FROM sessions WHERE user_id = ?", frank)
+		db.Exec("DELETE FROM users WHERE id = ?", frank)
+	}()
+
+	// Неизвестный логин сверяется с настоящим bcrypt-хешем, поэтому попытки
+	// идут одновременно; пройти проверку успевают только разрешённые.
+	const attempts = 8
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, _ := signInAttempt(t, "nobody", "guess")
+			codes <- code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+	assert.Equal(t, 4, counts[http.StatusUnauthorized])
+	assert.Equal(t, attempts-4, counts[http.StatusTooManyRequests])
+
+	time.Sleep(1100 * time.Millisecond)
+	code, _ := signInAttempt(t, "frank", "frank-password")
+	assert.Equal(t, http.StatusOK, code)
+}
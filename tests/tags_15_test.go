@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addTaggedTask(t *testing.T, title string, tags []string) string {
+	ret, err := postJSON("api/task", map[string]any{
+		"date":  "20320101",
+		"title": title,
+		"tags":  tags,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"])
+	id, _ := ret["id"].(string)
+	return id
+}
+
+// taggedTitles возвращает названия задач с метками tags через запятую.
+func taggedTitles(t *testing.T, tags, mode string) []string {
+	params := url.Values{"tags": {tags}}
+	if mode != "" {
+		params.Set("tags_mode", mode)
+	}
+	body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp struct {
+		Tasks []struct {
+			Title string `json:"title"`
+		} `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	titles := []string{}
+	for _, task := range resp.Tasks {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
+
+func getTags(t *testing.T) map[string]string {
+	body, err := requestJSON("api/tags", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp struct {
+		Tags []map[string]string `json:"tags"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	tags := make(map[string]string)
+	for _, tag := range resp.Tags {
+		tags[tag["name"]] = tag["tasks"]
+	}
+	return tags
+}
+
+func TestTags(t *testing.T) {
+	report := addTaggedTask(t, "Отчёт", []string{"Work", "urgent"})
+	flowers := addTaggedTask(t, "Полить цветы", []string{"home"})
+	bills := addTaggedTask(t, "Оплатить счета", []string{"work", " HOME ", "home"})
+
+	assert.ElementsMatch(t, []string{"Отчёт", "Оплатить счета"}, taggedTitles(t, "work", ""))
+	assert.ElementsMatch(t, []string{"Оплатить счета"}, taggedTitles(t, "work,home", "all"))
+	assert.ElementsMatch(t, []string{"Отчёт", "Полить цветы", "Оплатить счета"}, taggedTitles(t, "work,home", "any"))
+	assert.ElementsMatch(t, []string{"Отчёт", "Оплатить счета"}, taggedTitles(t, "work,WORK", ""))
+	assert.ElementsMatch(t, []string{"Оплатить счета"}, taggedTitles(t, "work,home,work", "all"))
+
+	// Метка другого пользователя с тем же именем не делает задачу подходящей.
+	db := openDB(t)
+	defer db.Close()
+	var foreignTag int
+	err := db.QueryRow("INSERT INTO tags (owner_id, name) VALUES (?, ?) RETURNING id", 999999, "чужая").Scan(&foreignTag)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?)", flowers, foreignTag)
+	assert.NoError(t, err)
+	assert.Empty(t, taggedTitles(t, "чужая", ""))
+	assert.Empty(t, taggedTitles(t, "чужая,home", "all"))
+	_, err = db.Exec("DELETE FROM task_tags WHERE tag_id = ?", foreignTag)
+	assert.NoError(t, err)
+	_, err = db.Exec("DELETE FROM tags WHERE id = ?", foreignTag)
+	assert.NoError(t, err)
+
+	body, err := requestJSON("api/task?id="+report, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var task struct {
+		Tags []string `json:"tags"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, []string{"urgent", "work"}, task.Tags)
+
+	update := map[string]any{"id": report, "date": "20320101", "title": "Отчёт за год"}
+	ret, err := postJSON("api/task", update, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.ElementsMatch(t, []string{"Отчёт за год"}, taggedTitles(t, "urgent", ""))
+
+	update["tags"] = []string{}
+	ret, err = postJSON("api/task", update, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, taggedTitles(t, "urgent", ""))
+
+	tags := getTags(t)
+	assert.Equal(t, "2", tags["home"])
+	assert.Equal(t, "1", tags["work"])
+	assert.Equal(t, "0", tags["urgent"])
+
+	ret, err = postJSON("api/tag?name=home", map[string]any{"name": "Work"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.ElementsMatch(t, []string{"Полить цветы", "Оплатить счета"}, taggedTitles(t, "work", ""))
+	tags = getTags(t)
+	assert.NotContains(t, tags, "home")
+	assert.Equal(t, "2", tags["work"])
+
+	ret, err = postJSON("api/tag?name=work", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, taggedTitles(t, "work", ""))
+	assert.NotContains(t, getTags(t), "work")
+
+	for _, params := range []string{"tags=a,,b", "tags=a&tags_mode=some"} {
+		body, err := requestJSON("api/tasks?"+params, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.NotEmpty(t, m["error"], params)
+	}
+	ret, err = postJSON("api/task", map[string]any{
+		"date": "20320101", "title": "Слишком длинная метка", "tags": []string{strings.Repeat("я", 65)},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	ret, err = postJSON("api/tag?name=nosuchtag", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	for _, id := range []string{report, flowers, bills} {
+		ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+}
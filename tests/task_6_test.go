@@ -130,3 +130,79 @@ func TestEditTask(t *testing.T) {
 		"repeat":  "d 7",
 	})
 }
+
+func TestTaskTime(t *testing.T) {
+	tbl := []map[string]any{
+		{"date": "20990101", "title": "Встреча", "time": "25:00"},
+		{"date": "20990101", "title": "Встреча", "time": "9:00"},
+		{"date": "20990101", "title": "Встреча", "duration": "30"},
+		{"date": "20990101", "title": "Встреча", "time": "10:00", "duration": "2000"},
+	}
+	for _, v := range tbl {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m["error"], "Ожидается ошибка для задачи %v", v)
+	}
+
+	var ids []string
+	for _, v := range []map[string]any{
+		{"date": "20990101", "title": "Созвон", "time": "16:00", "duration": "60"},
+		{"date": "20990101", "title": "Планёрка", "time": "09:30", "duration": "15"},
+		{"date": "20990101", "title": "Весь день"},
+	} {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, m["error"])
+		ids = append(ids, fmt.Sprint(m["id"]))
+	}
+
+	body, err := requestJSON("api/task?id="+ids[0], nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	assert.Equal(t, "16:00", m["time"])
+	assert.Equal(t, "60", m["duration"])
+
+	if Search {
+		tasks := getTasks(t, "01.01.2099")
+		assert.Len(t, tasks, 3)
+		if len(tasks) == 3 {
+			assert.Equal(t, ids[2], tasks[0]["id"])
+			assert.Equal(t, ids[1], tasks[1]["id"])
+			assert.Equal(t, ids[0], tasks[2]["id"])
+		}
+	}
+
+	// Правка без полей time и duration, как из web/, их не сбрасывает.
+	ret, err := postJSON("api/task", map[string]any{
+		"id": ids[0], "date": "20990101", "title": "Созвон с командой", "comment": "", "repeat": "",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	body, err = requestJSON("api/task?id="+ids[0], nil, http.MethodGet)
+	assert.NoError(t, err)
+	m = nil
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "Созвон с командой", m["title"])
+	assert.Equal(t, "16:00", m["time"])
+	assert.Equal(t, "60", m["duration"])
+
+	// Пустое время снимает и длительность.
+	ret, err = postJSON("api/task", map[string]any{
+		"id": ids[0], "date": "20990101", "title": "Созвон", "time": "",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	body, err = requestJSON("api/task?id="+ids[0], nil, http.MethodGet)
+	assert.NoError(t, err)
+	m = nil
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Empty(t, m["time"])
+	assert.Empty(t, m["duration"])
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
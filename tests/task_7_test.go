@@ -2,6 +2,7 @@ package tests
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
@@ -52,6 +53,121 @@ func TestDone(t *testing.T) {
 	}
 }
 
+func TestDoneSeriesEnd(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	ret, err := postJSON("api/task", map[string]any{
+		"date":         now.Format(`20060102`),
+		"title":        "Курс массажа",
+		"repeat":       "d 2",
+		"repeat_count": "2",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), task.Date)
+	assert.Equal(t, 1, task.RepeatCount)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":   now.Format(`20060102`),
+		"title":  "Отчёт",
+		"repeat": "RRULE:FREQ=DAILY;INTERVAL=3;UNTIL=" + now.AddDate(0, 0, 4).Format(`20060102`),
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id = fmt.Sprint(ret["id"])
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "RRULE:FREQ=DAILY;INTERVAL=3", task.Repeat)
+	assert.Equal(t, now.AddDate(0, 0, 4).Format(`20060102`), task.RepeatUntil)
+
+	for i := 0; i < 2; i++ {
+		ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	notFoundTask(t, id)
+}
+
+func TestEditKeepsSeriesEnd(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	until := time.Now().AddDate(0, 1, 0).Format(`20060102`)
+	ret, err := postJSON("api/task", map[string]any{
+		"date":         today,
+		"title":        "Курс уколов",
+		"repeat":       "d 2",
+		"repeat_count": "5",
+		"repeat_until": until,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	// Так задачу правит web/: без полей, о которых он не знает.
+	ret, err = postJSON("api/task", map[string]any{
+		"id":      id,
+		"date":    today,
+		"title":   "Курс уколов витамина",
+		"comment": "",
+		"repeat":  "d 3",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Курс уколов витамина", task.Title)
+	assert.Equal(t, "d 3", task.Repeat)
+	assert.Equal(t, 5, task.RepeatCount)
+	assert.Equal(t, until, task.RepeatUntil)
+
+	// Переданные поля меняются, в том числе на пустые.
+	ret, err = postJSON("api/task", map[string]any{
+		"id": id, "date": today, "title": "Курс уколов", "repeat": "d 3",
+		"repeat_count": "0", "repeat_until": "",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Zero(t, task.RepeatCount)
+	assert.Empty(t, task.RepeatUntil)
+
+	// Окончание в самом правиле RRULE заменяет прежнее.
+	_, err = db.Exec("UPDATE scheduler SET repeat_until = ? WHERE id = ?", until, id)
+	assert.NoError(t, err)
+	ret, err = postJSON("api/task", map[string]any{
+		"id": id, "date": today, "title": "Курс уколов", "repeat": "RRULE:FREQ=DAILY;COUNT=4",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, task.RepeatCount)
+	assert.Empty(t, task.RepeatUntil)
+
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+}
+
 func TestDelTask(t *testing.T) {
 	db := openDB(t)
 	defer db.Close()
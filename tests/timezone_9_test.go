@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimezone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, name := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("нет данных о часовом поясе %s", name)
+		}
+
+		m, err := postJSON("api/task?tz="+url.QueryEscape(name), map[string]any{
+			"title": "Задача в поясе " + name,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		id := fmt.Sprint(m["id"])
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Now().In(loc).Format(`20060102`), task.Date, name)
+
+		_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+
+	data, err := json.Marshal(map[string]any{"title": "Неизвестный пояс"})
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, getURL("api/task"), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Timezone", "Mars/Olympus_Mons")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	var m map[string]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	assert.NotEmpty(t, m["error"])
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTask(t *testing.T, id string) map[string]string {
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m
+}
+
+func TestRestore(t *testing.T) {
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	id := addTask(t, task{
+		date:    today,
+		title:   "Случайно удалённая",
+		comment: "очень важная",
+	})
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	body, err := requestJSON("api/trash", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var trash struct {
+		Items []struct {
+			Action string            `json:"action"`
+			Task   map[string]string `json:"task"`
+		} `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &trash))
+	assert.NotEmpty(t, trash.Items)
+	if len(trash.Items) > 0 {
+		assert.Equal(t, "delete", trash.Items[0].Action)
+		assert.Equal(t, id, trash.Items[0].Task["id"])
+	}
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, id, ret["id"])
+	m := getTask(t, id)
+	assert.Equal(t, "очень важная", m["comment"])
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	restored := id
+	id = addTask(t, task{
+		date:   today,
+		title:  "Выполнена по ошибке",
+		repeat: "d 5",
+	})
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, now.AddDate(0, 0, 5).Format(`20060102`), getTask(t, id)["date"])
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	assert.Equal(t, today, getTask(t, id)["date"])
+	assert.Empty(t, getCompletions(t, "api/task/history?id="+id))
+
+	for _, id := range []string{restored, id} {
+		_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userRequest выполняет запрос с токеном пользователя и возвращает код ответа
+// и ответ, разобранный как JSON-объект.
+func userRequest(t *testing.T, token, apipath string, values map[string]any, method string) (int, map[string]any) {
+	return authRequest(t, token, "", apipath, values, method)
+}
+
+// authRequest выполняет запрос с токеном в куке token или со значением
+// заголовка Authorization; пустые не передаются.
+func authRequest(t *testing.T, token, authorization, apipath string, values map[string]any, method string) (int, map[string]any) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	json.Unmarshal(body, &m)
+	return resp.StatusCode, m
+}
+
+func createUser(t *testing.T, db *sqlx.DB, login, password string) int {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+	var id int
+	err = db.QueryRow("INSERT INTO users (login, password_hash) VALUES (?, ?) RETURNING id",
+		login, string(hash)).Scan(&id)
+	assert.NoError(t, err)
+	return id
+}
+
+func signIn(t *testing.T, login, password string) string {
+	code, ret := userRequest(t, "", "api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	token, _ := ret["token"].(string)
+	assert.NotEmpty(t, token)
+	return token
+}
+
+func TestUsers(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	alice := createUser(t, db, "alice", "alice-password")
+	bob := createUser(t, db, "bob", "bob-password")
+	// Пока есть учётные записи, сервер требует входа, поэтому они удаляются
+	// сразу после теста.
+	defer func() {
+		for _, table := range []string{"scheduler", "tags", "projects", "completions", "trash"} {
+			db.Exec("DELETE FROM "+table+" WHERE owner_id IN (?, ?)", alice, bob)
+		}
+		db.Exec("DELETE FROM users WHERE id IN (?, ?)", alice, bob)
+	}()
+
+	code, _ := userRequest(t, "", "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, ret := userRequest(t, "", "api/signin", map[string]any{"login": "alice", "password": "bob-password"}, http.MethodPost)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.NotEmpty(t, ret["error"])
+
+	aliceToken := signIn(t, "alice", "alice-password")
+	bobToken := signIn(t, "bob", "bob-password")
+
+	today := time.Now().Format(`20060102`)
+	code, ret = userRequest(t, aliceToken, "api/task", map[string]any{
+		"date": today, "title": "Задача Алисы", "tags": []string{"работа"},
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+	id, _ := ret["id"].(string)
+	assert.NotEmpty(t, id)
+	code, _ = userRequest(t, bobToken, "api/task", map[string]any{
+		"date": today, "title": "Задача Боба", "tags": []string{"работа"},
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusOK, code)
+
+	titles := func(token string) []string {
+		_, ret := userRequest(t, token, "api/tasks", nil, http.MethodGet)
+		list := []string{}
+		tasks, _ := ret["tasks"].([]any)
+		for _, task := range tasks {
+			list = append(list, task.(map[string]any)["title"].(string))
+		}
+		return list
+	}
+	assert.Equal(t, []string{"Задача Алисы"}, titles(aliceToken))
+	assert.Equal(t, []string{"Задача Боба"}, titles(bobToken))
+
+	_, ret = userRequest(t, bobToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.NotEmpty(t, ret["error"])
+	_, ret = userRequest(t, bobToken, "api/task", map[string]any{
+		"id": id, "date": today, "title": "Чужая",
+	}, http.MethodPut)
+	assert.NotEmpty(t, ret["error"])
+	_, ret = userRequest(t, bobToken, "api/task/done?id="+id, nil, http.MethodPost)
+	assert.NotEmpty(t, ret["error"])
+	_, ret = userRequest(t, bobToken, "api/task?id="+id, nil, http.MethodDelete)
+	assert.NotEmpty(t, ret["error"])
+
+	_, ret = userRequest(t, aliceToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.Equal(t, "Задача Алисы", ret["title"])
+
+	// Метки у каждого пользователя свои, даже с одинаковыми именами.
+	_, ret = userRequest(t, aliceToken, "api/tags", nil, http.MethodGet)
+	tags, _ := ret["tags"].([]any)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, "1", tags[0].(map[string]any)["tasks"])
+	}
+}
@@ -0,0 +1,31 @@
+// Access-токен живёт недолго (TODO_ACCESS_TTL). Получив 401, клиент один раз
+// обменивает refresh-токен из куки на новую пару в /api/refresh и повторяет
+// запрос. Одновременные запросы ждут одного обмена: повторное предъявление
+// уже заменённого refresh-токена сервер считает кражей и закрывает сессию.
+(function () {
+    var refreshing = null;
+
+    function refresh() {
+        if (!refreshing) {
+            refreshing = axios.post("api/refresh").finally(function () {
+                refreshing = null;
+            });
+        }
+        return refreshing;
+    }
+
+    axios.interceptors.response.use(null, function (error) {
+        var config = error.config;
+        var status = error.response && error.response.status;
+        if (status !== 401 || !config || config._retried ||
+            /api\/(refresh|signin)/.test(config.url)) {
+            return Promise.reject(error);
+        }
+        config._retried = true;
+        return refresh().then(function () {
+            return axios(config);
+        }, function () {
+            return Promise.reject(error);
+        });
+    });
+})();